@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/logger"
+	"go.uber.org/zap"
+)
+
+// requestIDHeader передаёт идентификатор запроса от балансировщика
+// и возвращается в ответе, чтобы запрос можно было найти в журнале.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает чужой идентификатор, попадающий в журнал.
+const maxRequestIDLength = 128
+
+// requestID возвращает идентификатор из заголовка или создаёт новый,
+// если заголовка нет или он не похож на идентификатор.
+func requestID(header http.Header) string {
+	if id := header.Get(requestIDHeader); validRequestID(id) {
+		return id
+	}
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// accessLogMiddleware пишет в журнал каждый запрос вместе с ответом и кладёт
+// в контекст логгер с идентификатором запроса для остальных обработчиков.
+func accessLogMiddleware(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := requestID(r.Header)
+		w.Header().Set(requestIDHeader, id)
+
+		log := logger.Log.With(zap.String("request_id", id))
+		ctx, info := withRequestInfo(logger.WithContext(r.Context(), log))
+		rec := &statusRecorder{ResponseWriter: w}
+
+		h(rec, r.WithContext(ctx))
+
+		sessionID, messageID := info.Session()
+		log.Info("handled request",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Int("status", rec.Status()),
+			zap.Int("bytes", rec.bytes),
+			zap.Duration("duration", time.Since(start)),
+			zap.String("session_id", sessionID),
+			zap.Int64("message_id", messageID),
+			zap.String("command", info.Command()),
+		)
+	}
+}
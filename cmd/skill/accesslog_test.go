@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defer func(l *zap.Logger) { logger.Log = l }(logger.Log)
+	logger.Log = zap.New(core)
+
+	handler := accessLogMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		setSession(r.Context(), "session", 3)
+		setCommand(r.Context(), "send")
+		logger.FromContext(r.Context()).Debug("inside handler")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("hello"))
+	})
+
+	t.Run("propagated request ID", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+		r.Header.Set(requestIDHeader, "req-1")
+		w := httptest.NewRecorder()
+		handler(w, r)
+		assert.Equal(t, "req-1", w.Header().Get(requestIDHeader))
+
+		entries := logs.TakeAll()
+		require.Len(t, entries, 2)
+		assert.Equal(t, "req-1", entries[0].ContextMap()["request_id"])
+
+		access := entries[1]
+		assert.Equal(t, zapcore.InfoLevel, access.Level)
+		fields := access.ContextMap()
+		assert.Equal(t, "req-1", fields["request_id"])
+		assert.Equal(t, "POST", fields["method"])
+		assert.Equal(t, "/webhook", fields["path"])
+		assert.EqualValues(t, http.StatusAccepted, fields["status"])
+		assert.EqualValues(t, 5, fields["bytes"])
+		assert.Equal(t, "session", fields["session_id"])
+		assert.EqualValues(t, 3, fields["message_id"])
+		assert.Equal(t, "send", fields["command"])
+		assert.Contains(t, fields, "duration")
+	})
+
+	t.Run("generated request ID", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+		r.Header.Set(requestIDHeader, "bad id\n")
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		id := w.Header().Get(requestIDHeader)
+		assert.Len(t, id, 32)
+		entries := logs.TakeAll()
+		require.Len(t, entries, 2)
+		assert.Equal(t, id, entries[1].ContextMap()["request_id"])
+	})
+}
@@ -6,71 +6,413 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/VladimirAzanza/alisa_skill/internal/logger"
 	"github.com/VladimirAzanza/alisa_skill/internal/models"
+	"github.com/VladimirAzanza/alisa_skill/internal/moderation"
+	"github.com/VladimirAzanza/alisa_skill/internal/oauth"
+	"github.com/VladimirAzanza/alisa_skill/internal/plural"
+	"github.com/VladimirAzanza/alisa_skill/internal/resolver"
 	"github.com/VladimirAzanza/alisa_skill/internal/store"
+	"github.com/VladimirAzanza/alisa_skill/internal/username"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// flushInterval задаёт период пакетной записи сообщений из очереди.
+const flushInterval = 10 * time.Second
+
+// appConfig содержит настройки навыка.
+type appConfig struct {
+	usernames username.Policy
+	retention retentionConfig
+	// announceReceipts включает озвучивание отчётов о прочтении в начале сессии.
+	announceReceipts bool
+	limits           limitsConfig
+	// moderator проверяет тексты сообщений перед отправкой; nil отключает проверку.
+	moderator moderation.Moderator
+	// accountLinking разрешает привязку аккаунта через встроенный сервер авторизации.
+	accountLinking bool
+	// location используется, если Алиса не передала часовой пояс пользователя.
+	location *time.Location
+	// disabledCommands перечисляет начала команд, недоступных в навыке.
+	disabledCommands []string
+	// queueThreshold задаёт длину очереди отправки, при которой навык
+	// перестаёт считаться готовым; нулевое значение отключает проверку.
+	queueThreshold int
+}
+
+// retentionConfig задаёт работу фоновой очистки устаревших сообщений.
+type retentionConfig struct {
+	policy store.RetentionPolicy
+	// interval задаёт период запуска очистки; нулевое значение отключает её.
+	interval time.Duration
+	// batchSize ограничивает число строк, удаляемых одним запросом.
+	batchSize int
+}
+
 type app struct {
 	store   store.Store
-	msgChan chan store.Message
+	config  appConfig
+	spam    *spamGuard
+	msgChan chan queuedMessage
+	// flushBeat хранит время последней итерации flushMessages в наносекундах Unix.
+	flushBeat atomic.Int64
 }
 
-func newApp(s store.Store) *app {
+func newApp(s store.Store, config appConfig) *app {
 	instance := &app{
 		store:   s,
-		msgChan: make(chan store.Message, 1024),
+		config:  config,
+		spam:    newSpamGuard(config.limits),
+		msgChan: make(chan queuedMessage, 1024),
 	}
+	instance.flushBeat.Store(time.Now().UnixNano())
 	go instance.flushMessages()
+	if config.retention.interval > 0 {
+		go instance.purgeMessages()
+	}
 	return instance
 }
 
 func (s *app) webhook(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	log := logger.FromContext(ctx)
 
 	if r.Method != http.MethodPost {
-		logger.Log.Debug("got request with bad method", zap.String("method", r.Method))
+		log.Debug("got request with bad method", zap.String("method", r.Method))
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
 
-	logger.Log.Debug("decoding request")
+	log.Debug("decoding request")
 	var req models.Request
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&req); err != nil {
-		logger.Log.Debug("cannot decode request JSON body", zap.Error(err))
+		log.Debug("cannot decode request JSON body", zap.Error(err))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	setSession(ctx, req.Session.SessionID, req.Session.MessageID)
+	log = log.With(zap.String("session_id", req.Session.SessionID), zap.Int64("message_id", req.Session.MessageID))
 
-	if req.Request.Type != models.TypeSimpleUtterance {
-		logger.Log.Debug("unsupported request type", zap.String("type", req.Request.Type))
+	// после привязки аккаунта Алиса присылает событие вместо реплики
+	linkingComplete := req.Request.Type == models.TypeAccountLinkingComplete || req.AccountLinkingCompleteEvent != nil
+	if req.Request.Type != models.TypeSimpleUtterance && !linkingComplete {
+		log.Debug("unsupported request type", zap.String("type", req.Request.Type))
 		w.WriteHeader(http.StatusUnprocessableEntity)
 		return
 	}
 
-	var text string
+	// Алиса не передаёт пользователя, если он не вошёл в аккаунт Яндекса:
+	// такой пользователь определяется по приложению и не может отправлять сообщения
+	anonymous := req.Session.User.UserID == ""
+	applicationID := applicationScopedID(req.Session.Application.ApplicationID)
+	if anonymous {
+		if applicationID == "" {
+			log.Debug("request without user and application")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		req.Session.User.UserID = applicationID
+	}
+
+	// привязанный аккаунт передаёт токен доступа: тогда пользователь
+	// определяется по учётной записи каталога, к которой выдан токен
+	sessionUserID := req.Session.User.UserID
+	linked := false
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		account, err := s.store.FindTokenAccount(ctx, oauth.HashToken(token))
+		switch {
+		case err == nil:
+			linked, anonymous = true, false
+			req.Session.User.UserID = accountScopedID(account)
+		case errors.Is(err, store.ErrNotFound):
+			log.Warn("rejected unknown access token", zap.String("event", "security"), zap.String("remote_addr", r.RemoteAddr))
+		default:
+			log.Debug("cannot find access token", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// пользователь вошёл в аккаунт на устройстве, где пользовался навыком
+	// без авторизации: перенесём аккаунт приложения на аккаунт Яндекса
+	migrated := false
+	if !anonymous && req.Session.New && applicationID != "" {
+		err := s.store.MigrateUser(ctx, applicationID, req.Session.User.UserID)
+		switch {
+		case err == nil:
+			migrated = true
+			log.Info("migrated application account", zap.String("application_id", applicationID), zap.String("user_id", req.Session.User.UserID))
+		case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrConflict):
+		default:
+			log.Debug("cannot migrate application account", zap.Error(err))
+		}
+	}
+
+	var text, tts string
+	var card *models.Card
+	startLinking := false
+	// spoken содержит сообщения, которые отмечаются прочитанными
+	// только после успешной отправки ответа
+	var spoken []int64
+	state := req.State.Session
+
+	// подтверждение удаления аккаунта и продолжение чтения действуют только для следующей реплики
+	confirmDeletion := state.ConfirmDeletion
+	state.ConfirmDeletion = false
+	readAll := state.ReadAll
+	state.ReadAll = false
+
+	// если в прошлый раз мы уточняли получателя, отправим отложенное сообщение выбранному
+	if len(state.PendingRecepients) > 0 {
+		if choice := chooseCandidate(req.Request.Command, state.PendingRecepients); choice != "" {
+			req.Request.Command = fmt.Sprintf("Отправь %s %s", choice, state.PendingMessage)
+		}
+		state.PendingRecepients = nil
+		state.PendingMessage = ""
+	}
 
 	switch true {
+	case linkingComplete:
+		setCommand(ctx, "link_complete")
+		text = "Не получилось привязать аккаунт. Скажите «Привяжи аккаунт», чтобы попробовать ещё раз."
+		if !linked {
+			break
+		}
+
+		// перенесём аккаунт, которым пользовались до привязки
+		err := s.store.MigrateUser(ctx, sessionUserID, req.Session.User.UserID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) && !errors.Is(err, store.ErrConflict) {
+			log.Debug("cannot migrate linked account", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		text = "Аккаунт привязан. Теперь навык знает вас по корпоративной учётной записи."
+
+	case strings.HasPrefix(req.Request.Command, "Привяжи аккаунт"):
+		setCommand(ctx, "link_account")
+		switch {
+		case linked:
+			text = "Ваш аккаунт уже привязан."
+		case !s.config.accountLinking:
+			text = "В этом навыке привязка аккаунта не настроена."
+		default:
+			startLinking = true
+			text = "Войдите в корпоративную учётную запись, чтобы привязать аккаунт."
+		}
+
+	case s.commandDisabled(req.Request.Command):
+		setCommand(ctx, "disabled")
+		text = "Эта команда недоступна в этом навыке."
+
+	case anonymous && (strings.HasPrefix(req.Request.Command, "Отправь") || strings.HasPrefix(req.Request.Command, "Ответь")):
+		setCommand(ctx, "anonymous")
+		text = "Чтобы отправлять сообщения, войдите в аккаунт Яндекса в приложении, через которое говорите с Алисой. Ваш аккаунт в навыке сохранится."
+
+	case confirmDeletion && isConfirmation(req.Request):
+		setCommand(ctx, "delete_account")
+		err := s.store.DeleteUser(ctx, req.Session.User.UserID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Debug("cannot delete user", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = "Ваш аккаунт удалён. Вы можете зарегистрироваться снова в любой момент."
+
+	case confirmDeletion && isRejection(req.Request):
+		setCommand(ctx, "delete_account")
+		text = "Хорошо, ваш аккаунт останется."
+
+	case strings.HasPrefix(req.Request.Command, "Удали мой аккаунт"):
+		setCommand(ctx, "delete_account")
+		state.ConfirmDeletion = true
+		text = "Вы уверены, что хотите удалить аккаунт? Все полученные сообщения будут удалены. Скажите «да» для подтверждения."
+
+	case strings.HasPrefix(req.Request.Command, "Как меня зовут"):
+		setCommand(ctx, "whoami")
+		user, err := s.store.GetUser(ctx, req.Session.User.UserID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Debug("cannot load user", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = "Вы ещё не зарегистрированы. Скажите «Зарегистрируй» и своё имя."
+		if user != nil {
+			text = fmt.Sprintf("Вы зарегистрированы под именем %s", user.Username)
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Смени имя на"):
+		setCommand(ctx, "rename")
+		name := username.Normalize(parseRenameCommand(req.Request.Command))
+		if err := s.config.usernames.Validate(name); err != nil {
+			text = err.Error()
+			break
+		}
+
+		err := s.store.RenameUser(ctx, req.Session.User.UserID, name)
+		if err != nil && !errors.Is(err, store.ErrConflict) && !errors.Is(err, store.ErrNotFound) {
+			log.Debug("cannot rename user", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = fmt.Sprintf("Теперь вас зовут %s", name)
+		if errors.Is(err, store.ErrConflict) {
+			registrationConflicts.With("rename").Inc()
+			text, err = s.conflictText(ctx, name)
+			if err != nil {
+				log.Debug("cannot suggest usernames", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			text = "Вы ещё не зарегистрированы. Скажите «Зарегистрируй» и своё имя."
+		}
+
 	case strings.HasPrefix(req.Request.Command, "Отправь"):
+		setCommand(ctx, "send")
 		username, message := parseSendCommand(req.Request.Command)
 
-		recepientID, err := s.store.FindRecepient(ctx, username)
+		// время доставки учитывается, только если оно названо сразу после получателя:
+		// «Отправь Маше завтра в девять утра: не забудь ключи»
+		var deliverAt time.Time
+		if at, tokens, ok := parseDateTime(req.Request.Nlu, time.Now().In(s.userLocation(req))); ok && tokens.Start == 2 {
+			deliverAt = at
+			message = stripDateTime(message, req.Request.Nlu, tokens)
+		}
+
+		// срок жизни отсчитывается от момента доставки: «Отправь Маше на один час: код 1234»
+		var expiresAt time.Time
+		ttl, ttlPhrase, rest, hasTTL := parseTTL(message)
+		if hasTTL {
+			message = rest
+			expiresAt = time.Now().Add(ttl)
+			if !deliverAt.IsZero() {
+				expiresAt = deliverAt.Add(ttl)
+			}
+		}
+
+		message, reason := s.moderate(ctx, req.Request, message)
+		if reason != "" {
+			text = reason
+			break
+		}
+
+		// сначала проверим, не является ли получатель группой отправителя
+		group, err := s.findGroup(ctx, req.Session.User.UserID, username)
+		if err != nil {
+			log.Debug("cannot list groups", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var members []string
+		if group != "" {
+			members, err = s.store.ListGroupMembers(ctx, req.Session.User.UserID, group)
+			if err != nil {
+				log.Debug("cannot load group members", zap.String("group", group), zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if len(members) > 0 {
+			if reason := s.spam.checkSend(req.Session.User.UserID, message, time.Now()); reason != "" {
+				text = reason
+				break
+			}
+
+			// разошлём сообщение каждому участнику группы, кроме самого отправителя
+			queued := true
+			for _, memberID := range members {
+				if memberID == req.Session.User.UserID {
+					continue
+				}
+				if !s.spam.allowRecepient(memberID, time.Now()) {
+					log.Info("recepient rate limit exceeded", zap.String("recepient", memberID), zap.String("sender", req.Session.User.UserID))
+					continue
+				}
+				err := s.enqueue(ctx, store.Message{
+					Sender:    req.Session.User.UserID,
+					Recepient: memberID,
+					Time:      time.Now(),
+					Payload:   message,
+					Group:     group,
+					DeliverAt: deliverAt,
+					ExpiresAt: expiresAt,
+				})
+				if err != nil {
+					log.Warn("cannot queue message", zap.String("recepient", memberID), zap.Error(err))
+					queued = false
+					break
+				}
+			}
+			if !queued {
+				text = queueFullText
+				break
+			}
+
+			text = fmt.Sprintf("Сообщение успешно отправлено группе %s", username)
+			if !deliverAt.IsZero() {
+				text = fmt.Sprintf("Сообщение для группы %s будет доставлено %s", username, formatDeliverAt(deliverAt))
+			}
+			if hasTTL {
+				text = fmt.Sprintf("%s. Оно будет удалено через %s после доставки.", text, ttlPhrase)
+			}
+			break
+		}
+
+		recepientID, err := s.resolveRecepient(ctx, req.Session.User.UserID, username, parseFIONames(req.Request.Nlu))
+		if reason, ok := recepientErrorText(err, username); ok {
+			var ambiguous *ambiguousRecepientError
+			if errors.As(err, &ambiguous) {
+				state.PendingRecepients = ambiguous.candidates
+				state.PendingMessage = message
+			}
+			text = reason
+			break
+		}
 		if err != nil {
-			logger.Log.Debug("cannot find recepient by username", zap.String("username", username), zap.Error(err))
+			log.Debug("cannot find recepient by username", zap.String("username", username), zap.Error(err))
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		s.msgChan <- store.Message{
+		if reason := s.spam.checkSend(req.Session.User.UserID, message, time.Now()); reason != "" {
+			text = reason
+			break
+		}
+		if !s.spam.allowRecepient(recepientID, time.Now()) {
+			text = fmt.Sprintf("%s сейчас получает слишком много сообщений. Попробуйте немного позже.", username)
+			break
+		}
+
+		err = s.enqueue(ctx, store.Message{
 			Sender:    req.Session.User.UserID,
 			Recepient: recepientID,
 			Time:      time.Now(),
 			Payload:   message,
+			DeliverAt: deliverAt,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			log.Warn("cannot queue message", zap.String("recepient", recepientID), zap.Error(err))
+			text = queueFullText
+			break
 		}
 
 		// err = s.store.SaveMessage(ctx, recepientID, store.Message{
@@ -79,74 +421,719 @@ func (s *app) webhook(w http.ResponseWriter, r *http.Request) {
 		// 	Payload: message,
 		// })
 		// if err != nil {
-		// 	logger.Log.Debug("cannot save message", zap.String("recepient", recepientID), zap.Error(err))
+		// 	log.Debug("cannot save message", zap.String("recepient", recepientID), zap.Error(err))
 		// 	w.WriteHeader(http.StatusInternalServerError)
 		// 	return
 		// }
 
 		text = "Сообщение успешно отправлено"
+		if !deliverAt.IsZero() {
+			text = fmt.Sprintf("Сообщение будет доставлено %s", formatDeliverAt(deliverAt))
+		}
+		if hasTTL {
+			text = fmt.Sprintf("%s. Оно будет удалено через %s после доставки.", text, ttlPhrase)
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Какие сообщения запланированы"),
+		strings.HasPrefix(req.Request.Command, "Запланированные сообщения"):
+		setCommand(ctx, "scheduled")
+		messages, err := s.store.ListScheduled(ctx, req.Session.User.UserID)
+		if err != nil {
+			log.Debug("cannot load scheduled messages", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = "У вас нет запланированных сообщений."
+		if len(messages) > 0 {
+			lines := make([]string, 0, len(messages))
+			for i, message := range messages {
+				lines = append(lines, fmt.Sprintf("%d. Для %s %s: %s", i+1, message.Recepient, formatDeliverAt(message.DeliverAt.In(s.userLocation(req))), message.Payload))
+			}
+			text = fmt.Sprintf("Запланированные сообщения: %s. Чтобы отменить, скажите «Отмени сообщение» и номер.", strings.Join(lines, "; "))
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Отмени сообщение"):
+		setCommand(ctx, "cancel_scheduled")
+		index := parseNumberedCommand(req.Request.Command)
+
+		messages, err := s.store.ListScheduled(ctx, req.Session.User.UserID)
+		if err != nil {
+			log.Debug("cannot load scheduled messages", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = "Такого запланированного сообщения нет."
+		if index >= 0 && index < len(messages) {
+			err = s.store.CancelScheduled(ctx, req.Session.User.UserID, messages[index].ID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				log.Debug("cannot cancel scheduled message", zap.Int64("id", messages[index].ID), zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			text = fmt.Sprintf("Сообщение для %s отменено", messages[index].Recepient)
+			if errors.Is(err, store.ErrNotFound) {
+				// сообщение успели доставить между запросами
+				text = "Это сообщение уже доставлено."
+			}
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Прочитай все"), readAll && isConfirmation(req.Request):
+		setCommand(ctx, "read_all")
+		messages, err := s.store.ListUnread(ctx, req.Session.User.UserID, readAllBatch)
+		if err != nil {
+			log.Debug("cannot load unread messages", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = "Для вас нет новых сообщений."
+		if readAll {
+			text = "Больше новых сообщений нет."
+		}
+		if len(messages) > 0 {
+			page := buildReadAllPage(messages)
+			text, tts = page.text, page.tts
+			spoken = page.spoken
+			state.ReadAll = page.more
+			state.LastReadID = page.spoken[len(page.spoken)-1]
+		}
+
+	case readAll && isRejection(req.Request):
+		setCommand(ctx, "read_all")
+		text = "Хорошо, остальные сообщения прочитаю в следующий раз."
+
+	case strings.HasPrefix(req.Request.Command, "Прочитай от"):
+		setCommand(ctx, "read_from")
+		senderName := parseReadFromCommand(req.Request.Command)
+
+		senderID, err := s.resolveRecepient(ctx, req.Session.User.UserID, senderName, parseFIONames(req.Request.Nlu))
+		if reason, ok := recepientErrorText(err, senderName); ok {
+			text = reason
+			break
+		}
+		if err != nil {
+			log.Debug("cannot find sender by username", zap.String("username", senderName), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		messages, err := s.store.ListUnreadFrom(ctx, req.Session.User.UserID, senderID)
+		if err != nil {
+			log.Debug("cannot load unread messages from sender", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = fmt.Sprintf("Новых сообщений от %s нет.", senderName)
+		if len(messages) > 0 {
+			// вместо ответа уже ушла заглушка: сообщения останутся непрочитанными
+			if ctx.Err() != nil {
+				log.Info("request timed out, messages are left unread", zap.Error(ctx.Err()))
+				return
+			}
+			lines := make([]string, 0, len(messages))
+			for i, message := range messages {
+				if err := s.store.MarkRead(ctx, message.ID); err != nil {
+					log.Debug("cannot mark message as read", zap.Int64("id", message.ID), zap.Error(err))
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				lines = append(lines, fmt.Sprintf("%d. %s", i+1, message.Payload))
+			}
+
+			text = fmt.Sprintf("Сообщения от %s: %s", senderGenitive(messages[0].Sender), strings.Join(lines, " "))
+			// ответить можно на последнее прочитанное сообщение
+			state.LastReadID = messages[len(messages)-1].ID
+		}
 
 	case strings.HasPrefix(req.Request.Command, "Прочитай"):
+		setCommand(ctx, "read")
 		messageIndex := parseReadCommand(req.Request.Command)
 
 		messages, err := s.store.ListMessages(ctx, req.Session.User.UserID)
 		if err != nil {
-			logger.Log.Debug("cannot load messages for user", zap.Error(err))
+			log.Debug("cannot load messages for user", zap.Error(err))
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
 		text = "Для вас нет новых сообщений."
-		if len(messages) < messageIndex {
+		if messageIndex < 0 || len(messages) <= messageIndex {
 			text = "Такого сообщения не существует."
 		} else {
 			messageID := messages[messageIndex].ID
 			message, err := s.store.GetMessage(ctx, messageID)
+			if errors.Is(err, store.ErrNotFound) {
+				// сообщение удалили по истечении срока между запросами
+				text = "Такого сообщения не существует."
+				break
+			}
+			if err != nil {
+				log.Debug("cannot load message", zap.Int64("id", messageID), zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			// вместо ответа уже ушла заглушка: сообщение останется непрочитанным
+			if ctx.Err() != nil {
+				log.Info("request timed out, message is left unread", zap.Int64("id", message.ID), zap.Error(ctx.Err()))
+				return
+			}
+			if err := s.store.MarkRead(ctx, message.ID); err != nil {
+				log.Debug("cannot mark message as read", zap.Int64("id", message.ID), zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			sender := message.Sender
+			if sender == "" {
+				sender = "удалённого пользователя"
+			}
+
+			text = fmt.Sprintf("Сообщение от %s, отправлено %s: %s", sender, message.Time, message.Payload)
+			if message.Group != "" {
+				text = fmt.Sprintf("Сообщение от %s в группе %s, отправлено %s: %s", sender, message.Group, message.Time, message.Payload)
+			}
+			if message.ReplyTo != 0 {
+				original, err := s.store.GetMessage(ctx, message.ReplyTo)
+				if err != nil && !errors.Is(err, store.ErrNotFound) {
+					log.Debug("cannot load original message", zap.Int64("id", message.ReplyTo), zap.Error(err))
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				text = fmt.Sprintf("Ответ на ваше удалённое сообщение от %s, отправлено %s: %s", sender, message.Time, message.Payload)
+				if original != nil {
+					text = fmt.Sprintf("Ответ на ваше сообщение «%s» от %s, отправлено %s: %s", original.Payload, sender, message.Time, message.Payload)
+				}
+			}
+
+			// запомним прочитанное сообщение, чтобы на него можно было ответить
+			state.LastReadID = message.ID
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Прочитал ли"), strings.HasPrefix(req.Request.Command, "Прочитала ли"):
+		setCommand(ctx, "receipt")
+		name := parseReceiptCommand(req.Request.Command)
+
+		recepientID, err := s.resolveRecepient(ctx, req.Session.User.UserID, name, parseFIONames(req.Request.Nlu))
+		if reason, ok := recepientErrorText(err, name); ok {
+			text = reason
+			break
+		}
+		if err != nil {
+			log.Debug("cannot find recepient by username", zap.String("username", name), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		receipt, err := s.store.GetReceipt(ctx, req.Session.User.UserID, recepientID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) && !errors.Is(err, store.ErrReceiptsDisabled) {
+			log.Debug("cannot load read receipt", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			text = fmt.Sprintf("Вы не отправляли сообщений пользователю %s.", name)
+		case errors.Is(err, store.ErrReceiptsDisabled):
+			text = fmt.Sprintf("%s не сообщает о прочтении сообщений.", name)
+		case receipt.ReadAt.IsZero():
+			text = fmt.Sprintf("%s ещё не прочитал ваше последнее сообщение.", receipt.Recepient)
+		default:
+			text = fmt.Sprintf("%s прочитал ваше последнее сообщение %s.", receipt.Recepient, formatDeliverAt(receipt.ReadAt.In(s.userLocation(req))))
+		}
+
+	case strings.HasPrefix(resolver.Normalize(req.Request.Command), "не отправляй отчеты о прочтении"),
+		strings.HasPrefix(resolver.Normalize(req.Request.Command), "отправляй отчеты о прочтении"):
+		setCommand(ctx, "receipts_settings")
+		enabled := !strings.HasPrefix(resolver.Normalize(req.Request.Command), "не ")
+
+		err := s.store.SetSendReceipts(ctx, req.Session.User.UserID, enabled)
+		if err != nil {
+			log.Debug("cannot update read receipts setting", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = "Хорошо, отправители больше не узнают, когда вы прочитали их сообщения."
+		if enabled {
+			text = "Хорошо, отправители будут узнавать, когда вы прочитали их сообщения."
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Найди сообщения"):
+		setCommand(ctx, "search")
+		senderName, topic := parseSearchCommand(req.Request.Command)
+		query := store.SearchQuery{Text: topic, Limit: searchResultsLimit}
+
+		if senderName != "" {
+			senderID, err := s.resolveRecepient(ctx, req.Session.User.UserID, senderName, parseFIONames(req.Request.Nlu))
+			if reason, ok := recepientErrorText(err, senderName); ok {
+				text = reason
+				break
+			}
+			if err != nil {
+				log.Debug("cannot find sender by username", zap.String("username", senderName), zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			query.SenderID = senderID
+		}
+
+		// дата, например «за вчера», ограничивает поиск сутками
+		loc := s.userLocation(req)
+		if day, _, ok := parseDateTime(req.Request.Nlu, time.Now().In(loc)); ok {
+			query.From = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+			query.To = query.From.AddDate(0, 0, 1)
+		}
+
+		messages, err := s.store.SearchMessages(ctx, req.Session.User.UserID, query)
+		if err != nil {
+			log.Debug("cannot search messages", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = "Ничего не нашла."
+		if len(messages) > 0 {
+			items := make([]models.CardItem, 0, len(messages))
+			lines := make([]string, 0, len(messages))
+			for _, message := range messages {
+				sender := message.Sender
+				if sender == "" {
+					sender = "удалённого пользователя"
+				}
+				sentAt := formatDeliverAt(message.Time.In(loc))
+
+				items = append(items, models.CardItem{
+					Title:       truncate(fmt.Sprintf("От %s, %s", sender, sentAt), 128),
+					Description: truncate(message.Payload, 256),
+				})
+				lines = append(lines, fmt.Sprintf("от %s %s: %s", sender, sentAt, message.Payload))
+			}
+
+			text = fmt.Sprintf("Вот что нашла: %s.", strings.Join(lines, "; "))
+			card = &models.Card{
+				Type:   models.CardItemsList,
+				Header: &models.CardHeader{Text: "Найденные сообщения"},
+				Items:  items,
+			}
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Ответь"):
+		setCommand(ctx, "reply")
+		message, reason := s.moderate(ctx, req.Request, parseReplyCommand(req.Request.Command))
+		if reason != "" {
+			text = reason
+			break
+		}
+
+		text = "Сначала прочитайте сообщение, на которое хотите ответить."
+		if state.LastReadID != 0 {
+			original, err := s.store.GetMessage(ctx, state.LastReadID)
+			if errors.Is(err, store.ErrNotFound) {
+				text = "Это сообщение уже удалено, ответить на него нельзя."
+				break
+			}
+			if err != nil {
+				log.Debug("cannot load message", zap.Int64("id", state.LastReadID), zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			recepientID, err := s.store.FindRecepient(ctx, original.Sender)
+			if errors.Is(err, store.ErrNotFound) {
+				text = "Отправитель этого сообщения удалил свой аккаунт."
+				break
+			}
 			if err != nil {
-				logger.Log.Debug("cannot load message", zap.Int64("id", messageID), zap.Error(err))
+				log.Debug("cannot find recepient by username", zap.String("username", original.Sender), zap.Error(err))
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
 
-			text = fmt.Sprintf("Сообщение от %s, отправлено %s: %s", message.Sender, message.Time, message.Payload)
+			if reason := s.spam.checkSend(req.Session.User.UserID, message, time.Now()); reason != "" {
+				text = reason
+				break
+			}
+			if !s.spam.allowRecepient(recepientID, time.Now()) {
+				text = fmt.Sprintf("%s сейчас получает слишком много сообщений. Попробуйте немного позже.", original.Sender)
+				break
+			}
+
+			err = s.enqueue(ctx, store.Message{
+				Sender:    req.Session.User.UserID,
+				Recepient: recepientID,
+				Time:      time.Now(),
+				Payload:   message,
+				ReplyTo:   original.ID,
+			})
+			if err != nil {
+				log.Warn("cannot queue reply", zap.String("recepient", recepientID), zap.Error(err))
+				text = queueFullText
+				break
+			}
+
+			text = fmt.Sprintf("Ответ для %s успешно отправлен", original.Sender)
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Пожаловаться на сообщение"):
+		setCommand(ctx, "report")
+		text = "Сначала прочитайте сообщение, на которое хотите пожаловаться."
+		if state.LastReadID == 0 {
+			break
+		}
+
+		err := s.store.ReportMessage(ctx, req.Session.User.UserID, state.LastReadID)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			text = "Это сообщение уже удалено."
+		case errors.Is(err, store.ErrConflict):
+			text = "Вы уже пожаловались на это сообщение."
+		case err != nil:
+			log.Debug("cannot report message", zap.Int64("id", state.LastReadID), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		default:
+			log.Info("message reported", zap.Int64("id", state.LastReadID), zap.String("reporter", req.Session.User.UserID))
+			text = "Спасибо, жалоба отправлена, мы проверим отправителя. Чтобы больше не получать от него сообщений, скажите «Заблокируй» и его имя."
 		}
 
 	case strings.HasPrefix(req.Request.Command, "Зарегистрируй"):
-		username := parseRegisterCommand(req.Request.Command)
+		setCommand(ctx, "register")
+		name := username.Normalize(parseRegisterCommand(req.Request.Command))
+		if err := s.config.usernames.Validate(name); err != nil {
+			text = err.Error()
+			break
+		}
+
+		err := s.store.RegisterUser(ctx, req.Session.User.UserID, name)
+		if err != nil && !errors.Is(err, store.ErrConflict) {
+			log.Debug("cannot register user", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = fmt.Sprintf("Вы успешно зарегистрированы под именем %s", name)
+		if errors.Is(err, store.ErrConflict) {
+			registrationConflicts.With("register").Inc()
+			text, err = s.conflictText(ctx, name)
+			if err != nil {
+				log.Debug("cannot suggest usernames", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Напомни"):
+		setCommand(ctx, "remind")
+		loc := s.userLocation(req)
+
+		r, ok := parseReminderCommand(req.Request, time.Now().In(loc))
+		if !ok {
+			text = "Скажите, когда и о чём напомнить. Например: «Напомни мне в 18:00 купить хлеб»."
+			break
+		}
+
+		err := s.enqueue(ctx, store.Message{
+			Sender:     req.Session.User.UserID,
+			Recepient:  req.Session.User.UserID,
+			Time:       time.Now(),
+			Payload:    r.text,
+			DeliverAt:  r.at,
+			Reminder:   true,
+			Recurrence: r.recurrence,
+		})
+		if err != nil {
+			log.Warn("cannot queue reminder", zap.Error(err))
+			text = "Не получилось сохранить напоминание. Попробуйте немного позже."
+			break
+		}
+
+		text = fmt.Sprintf("Хорошо, напомню %s: %s", formatDeliverAt(r.at), r.text)
+		if r.recurrence != "" {
+			when := describeRecurrence(store.Message{Recurrence: r.recurrence, DeliverAt: r.at}, loc)
+			text = fmt.Sprintf("Хорошо, буду напоминать %s в %02d:%02d, начиная с %s: %s", when, r.at.Hour(), r.at.Minute(), formatDeliverAt(r.at), r.text)
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Мои напоминания"),
+		strings.HasPrefix(req.Request.Command, "Какие у меня напоминания"):
+		setCommand(ctx, "reminders")
+		reminders, err := s.store.ListReminders(ctx, req.Session.User.UserID)
+		if err != nil {
+			log.Debug("cannot load reminders", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = "У вас нет напоминаний."
+		if len(reminders) > 0 {
+			loc := s.userLocation(req)
+			lines := make([]string, 0, len(reminders))
+			for i, r := range reminders {
+				when := formatDeliverAt(r.DeliverAt.In(loc))
+				if r.Recurrence != "" {
+					when = fmt.Sprintf("%s, ближайшее %s", describeRecurrence(r, loc), when)
+				}
+				lines = append(lines, fmt.Sprintf("%d. %s: %s", i+1, when, r.Payload))
+			}
+			text = fmt.Sprintf("Ваши напоминания: %s. Чтобы удалить, скажите «Удали напоминание» и номер.", strings.Join(lines, "; "))
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Удали напоминание"):
+		setCommand(ctx, "delete_reminder")
+		index := parseNumberedCommand(req.Request.Command)
+
+		reminders, err := s.store.ListReminders(ctx, req.Session.User.UserID)
+		if err != nil {
+			log.Debug("cannot load reminders", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = "Такого напоминания нет."
+		if index >= 0 && index < len(reminders) {
+			err = s.store.DeleteReminder(ctx, req.Session.User.UserID, reminders[index].ID)
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				log.Debug("cannot delete reminder", zap.Int64("id", reminders[index].ID), zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			text = fmt.Sprintf("Напоминание «%s» удалено", reminders[index].Payload)
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Создай группу"):
+		setCommand(ctx, "create_group")
+		group := parseCreateGroupCommand(req.Request.Command)
 
-		err := s.store.RegisterUser(ctx, req.Session.User.UserID, username)
+		err := s.store.CreateGroup(ctx, req.Session.User.UserID, group)
 		if err != nil && !errors.Is(err, store.ErrConflict) {
-			logger.Log.Debug("cannot register user", zap.Error(err))
+			log.Debug("cannot create group", zap.String("group", group), zap.Error(err))
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		text = fmt.Sprintf("Вы успешно зарегистрированы под именем %s", username)
+		text = fmt.Sprintf("Группа %s создана", group)
 		if errors.Is(err, store.ErrConflict) {
-			text = "Извините, такое имя уже занято. Попробуйте другое."
+			text = fmt.Sprintf("У вас уже есть группа %s.", group)
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Сохрани контакт"):
+		setCommand(ctx, "save_contact")
+		username, alias := parseSaveContactCommand(req.Request.Command)
+		if username == "" {
+			text = "Скажите, например: «Сохрани контакт ivan как брат»."
+			break
+		}
+
+		contactID, err := s.resolveRecepient(ctx, req.Session.User.UserID, username, parseFIONames(req.Request.Nlu))
+		if reason, ok := recepientErrorText(err, username); ok {
+			text = reason
+			break
+		}
+		if err != nil {
+			log.Debug("cannot find contact by username", zap.String("username", username), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		err = s.store.SaveContact(ctx, req.Session.User.UserID, alias, contactID)
+		if err != nil && !errors.Is(err, store.ErrConflict) {
+			log.Debug("cannot save contact", zap.String("alias", alias), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = fmt.Sprintf("Контакт %s сохранён как %s", username, alias)
+		if errors.Is(err, store.ErrConflict) {
+			text = fmt.Sprintf("У вас уже есть контакт %s.", alias)
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Удали контакт"):
+		setCommand(ctx, "remove_contact")
+		alias := parseRemoveContactCommand(req.Request.Command)
+
+		err := s.store.RemoveContact(ctx, req.Session.User.UserID, alias)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Debug("cannot remove contact", zap.String("alias", alias), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = fmt.Sprintf("Контакт %s удалён", alias)
+		if errors.Is(err, store.ErrNotFound) {
+			text = fmt.Sprintf("У вас нет контакта %s.", alias)
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Заблокируй"), strings.HasPrefix(req.Request.Command, "Разблокируй"):
+		command := "unblock"
+		if strings.HasPrefix(req.Request.Command, "Заблокируй") {
+			command = "block"
+		}
+		setCommand(ctx, command)
+		username := parseBlockCommand(req.Request.Command)
+
+		blockedID, err := s.resolveRecepient(ctx, req.Session.User.UserID, username, parseFIONames(req.Request.Nlu))
+		if reason, ok := recepientErrorText(err, username); ok {
+			text = reason
+			break
+		}
+		if err != nil {
+			log.Debug("cannot find user by username", zap.String("username", username), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if strings.HasPrefix(req.Request.Command, "Заблокируй") {
+			err = s.store.BlockUser(ctx, req.Session.User.UserID, blockedID)
+			text = fmt.Sprintf("%s заблокирован, вы больше не будете получать от него сообщения", username)
+		} else {
+			err = s.store.UnblockUser(ctx, req.Session.User.UserID, blockedID)
+			text = fmt.Sprintf("%s разблокирован", username)
+		}
+		if err != nil {
+			log.Debug("cannot update block list", zap.String("username", username), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Принимай сообщения"):
+		setCommand(ctx, "contacts_only")
+		contactsOnly := strings.Contains(req.Request.Command, "только от контактов")
+
+		err := s.store.SetContactsOnly(ctx, req.Session.User.UserID, contactsOnly)
+		if err != nil {
+			log.Debug("cannot update contacts only setting", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		text = "Теперь вы принимаете сообщения от всех пользователей"
+		if contactsOnly {
+			text = "Теперь вы принимаете сообщения только от своих контактов"
+		}
+
+	case strings.HasPrefix(req.Request.Command, "Добавь"), strings.HasPrefix(req.Request.Command, "Удали"):
+		command := "remove_member"
+		if strings.HasPrefix(req.Request.Command, "Добавь") {
+			command = "add_member"
+		}
+		setCommand(ctx, command)
+		username, group := parseGroupMemberCommand(req.Request.Command)
+
+		memberID, err := s.resolveRecepient(ctx, req.Session.User.UserID, username, parseFIONames(req.Request.Nlu))
+		if reason, ok := recepientErrorText(err, username); ok {
+			text = reason
+			break
+		}
+		if err != nil {
+			log.Debug("cannot find group member by username", zap.String("username", username), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		// группу называют в любом падеже: «добавь ivan в группу семью»
+		if found, err := s.findGroup(ctx, req.Session.User.UserID, group); err != nil {
+			log.Debug("cannot list groups", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		} else if found != "" {
+			group = found
+		}
+
+		if strings.HasPrefix(req.Request.Command, "Добавь") {
+			err = s.store.AddGroupMember(ctx, req.Session.User.UserID, group, memberID)
+			text = fmt.Sprintf("%s добавлен в группу %s", username, group)
+		} else {
+			err = s.store.RemoveGroupMember(ctx, req.Session.User.UserID, group, memberID)
+			text = fmt.Sprintf("%s удалён из группы %s", username, group)
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Debug("cannot update group members", zap.String("group", group), zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if errors.Is(err, store.ErrNotFound) {
+			text = fmt.Sprintf("У вас нет группы %s.", group)
 		}
 
 	default:
-		messages, err := s.store.ListMessages(ctx, req.Session.User.UserID)
+		setCommand(ctx, "summary")
+		summaries, err := s.store.SummarizeUnread(ctx, req.Session.User.UserID)
 		if err != nil {
-			logger.Log.Debug("cannot load messages for user", zap.Error(err))
+			log.Debug("cannot summarize messages for user", zap.Error(err))
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
 		text = "Для вас нет новых сообщений."
-		if len(messages) > 0 {
-			text = fmt.Sprintf("Для вас %d новых сообщений.", len(messages))
+		if len(summaries) > 0 {
+			total := 0
+			parts := make([]string, 0, len(summaries))
+			for _, summary := range summaries {
+				total += summary.Count
+				parts = append(parts, fmt.Sprintf("%d от %s", summary.Count, senderGenitive(summary.Sender)))
+			}
+			text = fmt.Sprintf("Для вас %d %s: %s.", total, plural.Form(total, "новое сообщение", "новых сообщения", "новых сообщений"), strings.Join(parts, ", "))
 		}
 
 		if req.Session.New {
+			reminders, err := s.store.ListDueReminders(ctx, req.Session.User.UserID)
+			if err != nil {
+				log.Debug("cannot load due reminders", zap.Error(err))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			if len(reminders) > 0 {
+				announcements := make([]string, 0, len(reminders))
+				for _, r := range reminders {
+					announcements = append(announcements, fmt.Sprintf("Напоминание: %s.", r.Payload))
+
+					// разовые напоминания гасятся, периодические переносятся на следующий срок
+					err := s.store.CompleteReminder(ctx, r.ID, nextOccurrence(r, time.Now().In(s.userLocation(req))))
+					if err != nil {
+						log.Debug("cannot complete reminder", zap.Int64("id", r.ID), zap.Error(err))
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+				}
+				text = strings.Join(announcements, " ") + " " + text
+			}
+
 			tz, err := time.LoadLocation(req.Timezone)
 			if err != nil {
-				logger.Log.Debug("cannot parse timezone")
+				log.Debug("cannot parse timezone")
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
 
+			if s.config.announceReceipts {
+				receipts, err := s.store.TakeReadReceipts(ctx, req.Session.User.UserID)
+				if err != nil {
+					log.Debug("cannot load read receipts", zap.Error(err))
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+
+				if len(receipts) > 0 {
+					sort.Slice(receipts, func(i, j int) bool { return receipts[i].ReadAt.Before(receipts[j].ReadAt) })
+
+					announcements := make([]string, 0, len(receipts))
+					for _, receipt := range receipts {
+						readAt := receipt.ReadAt.In(tz)
+						announcements = append(announcements, fmt.Sprintf("%s прочитал ваше сообщение в %02d:%02d.", receipt.Recepient, readAt.Hour(), readAt.Minute()))
+					}
+					text = strings.Join(announcements, " ") + " " + text
+				}
+			}
+
 			now := time.Now().In(tz)
 			hour, minute, _ := now.Clock()
 
@@ -154,48 +1141,304 @@ func (s *app) webhook(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if migrated {
+		text = "Ваш аккаунт в навыке теперь привязан к аккаунту Яндекса. " + text
+	}
+
 	resp := models.Response{
 		Response: models.ResponsePayload{
 			Text: text,
+			TTS:  tts,
+			Card: card,
 		},
-		Version: "1.0",
+		SessionState: state,
+		Version:      "1.0",
+	}
+	if startLinking {
+		resp.StartAccountLinking = &models.AccountLinking{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 
 	enc := json.NewEncoder(w)
 	if err := enc.Encode(resp); err != nil {
-		logger.Log.Debug("error encoding response", zap.Error(err))
+		log.Debug("error encoding response", zap.Error(err))
 		return
 	}
-	logger.Log.Debug("sending HTTP 200 response")
+
+	// ответ мог не успеть: тогда пользователь услышал заглушку, а не сообщения
+	if ctx.Err() != nil {
+		log.Info("request timed out, spoken messages are left unread", zap.Error(ctx.Err()))
+		return
+	}
+	for _, id := range spoken {
+		if err := s.store.MarkRead(ctx, id); err != nil {
+			log.Debug("cannot mark message as read", zap.Int64("id", id), zap.Error(err))
+		}
+	}
+	log.Debug("sending HTTP 200 response")
+}
+
+// senderGenitive возвращает имя отправителя в родительном падеже для фраз вида «от Ивана».
+func senderGenitive(sender string) string {
+	if sender == "" {
+		return "удалённого пользователя"
+	}
+	return resolver.Genitive(sender)
+}
+
+// searchResultsLimit ограничивает выдачу поиска размером карточки ItemsList.
+const searchResultsLimit = 5
+
+// truncate обрезает строку до limit символов, чтобы она поместилась в поле карточки.
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// accountScopedID возвращает идентификатор пользователя, привязавшего
+// учётную запись каталога. Префикс не даёт ему совпасть с user_id.
+func accountScopedID(account string) string {
+	return "account:" + account
+}
+
+// applicationScopedID возвращает идентификатор пользователя, действующий
+// в пределах одного приложения. Префикс не даёт ему совпасть с user_id.
+func applicationScopedID(applicationID string) string {
+	if applicationID == "" {
+		return ""
+	}
+	return "app:" + applicationID
+}
+
+// userLocation возвращает часовой пояс пользователя из запроса или пояс
+// навыка по умолчанию (UTC, если он не задан), если пояс не указан или неизвестен.
+func (s *app) userLocation(req models.Request) *time.Location {
+	fallback := time.UTC
+	if s.config.location != nil {
+		fallback = s.config.location
+	}
+	if req.Timezone == "" {
+		return fallback
+	}
+	tz, err := time.LoadLocation(req.Timezone)
+	if err != nil {
+		return fallback
+	}
+	return tz
+}
+
+// formatDeliverAt форматирует время доставки для озвучивания.
+func formatDeliverAt(t time.Time) string {
+	return fmt.Sprintf("%02d.%02d в %02d:%02d", t.Day(), t.Month(), t.Hour(), t.Minute())
+}
+
+// conflictText объясняет, что имя занято, и предлагает свободные варианты.
+func (s *app) conflictText(ctx context.Context, name string) (string, error) {
+	var free []string
+	for _, candidate := range s.config.usernames.Alternatives(name, 5) {
+		_, err := s.store.FindRecepient(ctx, candidate)
+		if errors.Is(err, store.ErrNotFound) {
+			free = append(free, candidate)
+		} else if err != nil {
+			return "", err
+		}
+		if len(free) == 2 {
+			break
+		}
+	}
+
+	if len(free) == 0 {
+		return "Извините, такое имя уже занято. Попробуйте другое.", nil
+	}
+	return fmt.Sprintf("Извините, такое имя уже занято. Свободны, например, %s.", strings.Join(free, " и ")), nil
+}
+
+// moderate проверяет текст сообщения перед отправкой и возвращает текст,
+// который можно доставить, или причину отказа для озвучивания.
+func (s *app) moderate(ctx context.Context, utterance models.SimpleUtterance, message string) (string, string) {
+	// Алиса помечает реплики об опасных ситуациях: такие сообщения не пересылаем
+	if utterance.Markup.DangerousContext {
+		return "", "Я не могу передать такое сообщение. Если вам нужна помощь, позвоните по номеру 112."
+	}
+	if s.config.moderator == nil {
+		return message, ""
+	}
+
+	moderated, err := s.config.moderator.Moderate(ctx, message)
+	if errors.Is(err, moderation.ErrRejected) {
+		return "", "Такое сообщение отправить нельзя. Попробуйте сказать иначе."
+	}
+	if err != nil {
+		logger.FromContext(ctx).Debug("cannot moderate message", zap.Error(err))
+		return "", "Не получилось проверить сообщение. Попробуйте отправить его позже."
+	}
+	return moderated, ""
+}
+
+// ambiguousRecepientError возвращается resolveRecepient, когда произнесённому
+// имени одинаково хорошо соответствуют несколько получателей.
+type ambiguousRecepientError struct {
+	candidates []string
+}
+
+func (e *ambiguousRecepientError) Error() string {
+	return fmt.Sprintf("ambiguous recepient: %s", strings.Join(e.candidates, ", "))
+}
+
+// recepientErrorText возвращает фразу, объясняющую пользователю,
+// почему получатель не найден. Для прочих ошибок возвращается false.
+func recepientErrorText(err error, name string) (string, bool) {
+	var ambiguous *ambiguousRecepientError
+	switch {
+	case errors.As(err, &ambiguous):
+		return fmt.Sprintf("Вы имели в виду %s?", strings.Join(ambiguous.candidates, " или ")), true
+	case errors.Is(err, store.ErrNotFound):
+		return fmt.Sprintf("Не нашла пользователя с именем %s.", name), true
+	}
+	return "", false
+}
+
+// findGroup возвращает название группы пользователя ownerID, совпадающее
+// с произнесённым name с точностью до падежа, или пустую строку.
+func (s *app) findGroup(ctx context.Context, ownerID, name string) (string, error) {
+	groups, err := s.store.ListGroups(ctx, ownerID)
+	if err != nil {
+		return "", err
+	}
+	stem := resolver.Stem(name)
+	for _, group := range groups {
+		if resolver.Stem(group) == stem {
+			return group, nil
+		}
+	}
+	return "", nil
+}
+
+// resolveRecepient находит идентификатор пользователя по псевдониму
+// из списка контактов пользователя userID или по зарегистрированному имени.
+// Если точного совпадения нет, имя ищется с учётом падежей и ошибок
+// распознавания среди контактов и пользователей; hints дополняют поиск
+// вариантами имени, например из сущности YANDEX.FIO.
+func (s *app) resolveRecepient(ctx context.Context, userID, name string, hints []string) (string, error) {
+	contactID, err := s.store.FindContact(ctx, userID, resolver.Normalize(name))
+	if err == nil {
+		return contactID, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return "", err
+	}
+
+	recepientID, err := s.store.FindRecepient(ctx, name)
+	if !errors.Is(err, store.ErrNotFound) {
+		return recepientID, err
+	}
+
+	queries := append([]string{name}, hints...)
+
+	contacts, err := s.store.ListContacts(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	usernames, err := s.store.FindUsernames(ctx, resolver.Initials(queries))
+	if err != nil {
+		return "", err
+	}
+
+	candidates := usernames
+	for alias := range contacts {
+		candidates = append(candidates, alias)
+	}
+
+	matches := resolver.Match(queries, candidates)
+	if len(matches) == 0 {
+		return "", store.ErrNotFound
+	}
+	if len(matches) > 1 {
+		return "", &ambiguousRecepientError{candidates: matches}
+	}
+
+	if contactID, ok := contacts[matches[0]]; ok {
+		return contactID, nil
+	}
+	return s.store.FindRecepient(ctx, matches[0])
 }
 
 func (a *app) flushMessages() {
 	// будем сохранять сообщения, накопленные за последние 10 секунд
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(flushInterval)
 
 	var messages []store.Message
+	// links ссылаются на запросы, которые отправили сообщения
+	var links []trace.Link
 
 	for {
+		a.flushBeat.Store(time.Now().UnixNano())
 		select {
 		case msg := <-a.msgChan:
 			// добавим сообщение в слайс для последующего сохранения
-			messages = append(messages, msg)
+			messages = append(messages, msg.Message)
+			if msg.span.IsValid() {
+				links = append(links, trace.Link{SpanContext: msg.span})
+			}
 		case <-ticker.C:
 			// подождём, пока придёт хотя бы одно сообщение
 			if len(messages) == 0 {
 				continue
 			}
 			// сохраним все пришедшие сообщения одновременно
-			err := a.store.SaveMessages(context.TODO(), messages...)
+			ctx, span := tracer().Start(context.Background(), "flush messages",
+				trace.WithLinks(links...),
+				trace.WithAttributes(attribute.Int("messages.count", len(messages))),
+			)
+			start := time.Now()
+			err := a.store.SaveMessages(ctx, messages...)
+			flushDuration.With().Observe(time.Since(start).Seconds())
+			if err != nil {
+				setSpanError(span, err)
+			}
+			span.End()
 			if err != nil {
+				flushFailures.With().Inc()
 				logger.Log.Debug("cannot save messages", zap.Error(err))
 				// не будем стирать сообщения, попробуем отправить их чуть позже
 				continue
 			}
+			flushBatchSize.With().Observe(float64(len(messages)))
 			// сотрём успешно отосланные сообщения
 			messages = nil
+			links = nil
+		}
+	}
+}
+
+// purgeMessages периодически удаляет сообщения с истёкшим сроком жизни
+// или сроком хранения. За один запуск удаляется столько пакетов, сколько нужно,
+// чтобы очистить накопившиеся строки, но каждый пакет не больше batchSize.
+func (a *app) purgeMessages() {
+	ticker := time.NewTicker(a.config.retention.interval)
+
+	for range ticker.C {
+		var total int64
+		for {
+			removed, err := a.store.PurgeExpired(context.TODO(), a.config.retention.policy, a.config.retention.batchSize)
+			if err != nil {
+				purgeFailures.With().Inc()
+				logger.Log.Debug("cannot purge expired messages", zap.Error(err))
+				break
+			}
+			total += removed
+			purgedMessages.With().Add(float64(removed))
+			// неполный пакет означает, что устаревших сообщений больше нет
+			if removed == 0 || removed < int64(a.config.retention.batchSize) {
+				break
+			}
+		}
+		if total > 0 {
+			logger.Log.Info("purged expired messages", zap.Int64("rows", total), zap.Bool("archive", a.config.retention.policy.Archive))
 		}
 	}
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Заголовки, которыми прокси перед навыком подтверждает подлинность запроса.
+const (
+	secretHeader    = "X-Skill-Secret"
+	signatureHeader = "X-Skill-Signature"
+	signaturePrefix = "sha256="
+)
+
+// authConfig задаёт проверку входящих запросов. Пустые поля отключают
+// соответствующую проверку.
+type authConfig struct {
+	// skillIDs перечисляет навыки, запросы которых принимаются.
+	skillIDs []string
+	// secret сравнивается со значением заголовка X-Skill-Secret.
+	secret string
+	// hmacKey проверяет подпись X-Skill-Signature: sha256=<hex>,
+	// вычисленную по распакованному телу запроса.
+	hmacKey string
+}
+
+// errOpenWebhook означает, что вебхук принимал бы запросы от кого угодно.
+var errOpenWebhook = errors.New("webhook accepts requests from any skill: set -skill-ids, -webhook-secret or -webhook-hmac-key, or -insecure-webhook for development")
+
+// requireAuth не даёт запустить открытый вебхук: без списка навыков, секрета
+// и ключа подписи навык отвечал бы на любой запрос из интернета.
+// insecure разрешает такой запуск для разработки.
+func requireAuth(config authConfig, insecure bool) error {
+	if len(config.skillIDs) > 0 || config.secret != "" || config.hmacKey != "" {
+		return nil
+	}
+	if !insecure {
+		return errOpenWebhook
+	}
+	return nil
+}
+
+// authMiddleware отклоняет запросы, которые не прошли проверку подлинности,
+// и записывает событие безопасности. Тело запроса читается целиком и
+// передаётся обработчику без изменений.
+func authMiddleware(config authConfig, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			h(w, r)
+			return
+		}
+
+		body, err := readBody(r)
+		if err != nil {
+			logger.FromContext(r.Context()).Debug("cannot read request body", zap.Error(err))
+			w.WriteHeader(bodyErrorStatus(err))
+			return
+		}
+
+		if reason := authenticate(config, r.Header, body); reason != "" {
+			logger.FromContext(r.Context()).Warn("rejected unauthenticated request",
+				zap.String("event", "security"),
+				zap.String("reason", reason),
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.String("user_agent", r.UserAgent()),
+			)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// authenticate возвращает причину отказа или пустую строку, если запрос подлинный.
+func authenticate(config authConfig, header http.Header, body []byte) string {
+	if config.secret != "" {
+		secret := header.Get(secretHeader)
+		if subtle.ConstantTimeCompare([]byte(secret), []byte(config.secret)) != 1 {
+			return "shared secret mismatch"
+		}
+	}
+
+	if config.hmacKey != "" {
+		signature, ok := strings.CutPrefix(header.Get(signatureHeader), signaturePrefix)
+		got, err := hex.DecodeString(signature)
+		if !ok || err != nil {
+			return "malformed signature"
+		}
+		mac := hmac.New(sha256.New, []byte(config.hmacKey))
+		mac.Write(body)
+		if !hmac.Equal(got, mac.Sum(nil)) {
+			return "signature mismatch"
+		}
+	}
+
+	if len(config.skillIDs) > 0 {
+		if skillID := requestSkillID(body); !slices.Contains(config.skillIDs, skillID) {
+			return "unknown skill_id " + skillID
+		}
+	}
+
+	return ""
+}
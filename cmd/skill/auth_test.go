@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	const body = `{"session": {"skill_id": "skill-1", "user": {"user_id": "user"}}}`
+
+	sign := func(key, body string) string {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(body))
+		return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	testCases := []struct {
+		name         string
+		config       authConfig
+		body         string
+		header       http.Header
+		expectedCode int
+	}{
+		{
+			name:         "no_checks",
+			body:         body,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "allowed_skill",
+			config:       authConfig{skillIDs: []string{"skill-1", "skill-2"}},
+			body:         body,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "unknown_skill",
+			config:       authConfig{skillIDs: []string{"skill-2"}},
+			body:         body,
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "secret",
+			config:       authConfig{secret: "s3cr3t"},
+			body:         body,
+			header:       http.Header{secretHeader: {"s3cr3t"}},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "wrong_secret",
+			config:       authConfig{secret: "s3cr3t"},
+			body:         body,
+			header:       http.Header{secretHeader: {"guess"}},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "signature",
+			config:       authConfig{hmacKey: "key"},
+			body:         body,
+			header:       http.Header{signatureHeader: {sign("key", body)}},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "forged_signature",
+			config:       authConfig{hmacKey: "key"},
+			body:         body,
+			header:       http.Header{signatureHeader: {sign("other", body)}},
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			name:         "missing_signature",
+			config:       authConfig{hmacKey: "key"},
+			body:         body,
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var received string
+			handler := authMiddleware(tc.config, func(w http.ResponseWriter, r *http.Request) {
+				b, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				received = string(b)
+			})
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			for k, v := range tc.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			if tc.expectedCode == http.StatusOK {
+				assert.Equal(t, tc.body, received, "handler must receive the original body")
+			}
+		})
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	assert.ErrorIs(t, requireAuth(authConfig{}, false), errOpenWebhook)
+	assert.NoError(t, requireAuth(authConfig{}, true))
+	assert.NoError(t, requireAuth(authConfig{skillIDs: []string{"skill-1"}}, false))
+	assert.NoError(t, requireAuth(authConfig{secret: "secret"}, false))
+	assert.NoError(t, requireAuth(authConfig{hmacKey: "key"}, false))
+}
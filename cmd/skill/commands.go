@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/models"
+	"github.com/VladimirAzanza/alisa_skill/internal/resolver"
+)
+
+// parseSendCommand разбирает команду вида "Отправь <username> <текст>"
+// и возвращает имя получателя и текст сообщения.
+func parseSendCommand(command string) (username string, message string) {
+	fields := strings.Fields(command)
+	if len(fields) < 2 {
+		return "", ""
+	}
+	return fields[1], strings.Join(fields[2:], " ")
+}
+
+// parseReadCommand разбирает команду вида "Прочитай <N>" и возвращает
+// индекс сообщения, начиная с нуля. Без номера читается первое сообщение.
+func parseReadCommand(command string) int {
+	fields := strings.Fields(command)
+	if len(fields) < 2 {
+		return 0
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0
+	}
+	return n - 1
+}
+
+// parseReadFromCommand разбирает команду вида "Прочитай от <имя>"
+// и возвращает имя отправителя.
+func parseReadFromCommand(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) < 3 {
+		return ""
+	}
+	return strings.Join(fields[2:], " ")
+}
+
+// parseRegisterCommand разбирает команду вида "Зарегистрируй <username>"
+// и возвращает желаемое имя пользователя.
+func parseRegisterCommand(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// parseReplyCommand разбирает команду вида "Ответь: <текст>"
+// и возвращает текст ответа.
+func parseReplyCommand(command string) string {
+	message := strings.TrimPrefix(command, "Ответь")
+	return strings.TrimSpace(strings.TrimLeft(message, ":, "))
+}
+
+// parseNumberedCommand разбирает команды вида "Отмени сообщение <N>"
+// и "Удали напоминание <N>" и возвращает индекс элемента списка, начиная с нуля,
+// или -1, если номер не назван.
+func parseNumberedCommand(command string) int {
+	fields := strings.Fields(command)
+	if len(fields) < 3 {
+		return -1
+	}
+	n, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return -1
+	}
+	return n - 1
+}
+
+// stripDateTime убирает из текста сообщения слова, задающие время доставки.
+// Если сразу после слов времени стоит двоеточие, остаётся только текст после него,
+// иначе текст собирается из токенов команды без токенов сущности.
+// Двоеточие внутри времени вроде «18:00» разделителем не считается.
+func stripDateTime(message string, nlu models.Nlu, tokens models.EntityTokens) string {
+	if tokens.Start <= tokens.End && tokens.End <= len(nlu.Tokens) {
+		if after, ok := cutAfterTokens(message, nlu.Tokens[tokens.Start:tokens.End]); ok {
+			if rest, found := strings.CutPrefix(strings.TrimSpace(after), ":"); found {
+				return strings.TrimSpace(rest)
+			}
+		}
+	}
+
+	var words []string
+	for i, token := range nlu.Tokens {
+		// первые два токена — команда и получатель
+		if i < 2 || (i >= tokens.Start && i < tokens.End) {
+			continue
+		}
+		words = append(words, token)
+	}
+	return strings.Join(words, " ")
+}
+
+// cutAfterTokens находит в тексте токены по порядку и возвращает текст после последнего.
+func cutAfterTokens(text string, tokens []string) (string, bool) {
+	rest := strings.ToLower(text)
+	if len(rest) != len(text) {
+		return "", false
+	}
+	for _, token := range tokens {
+		i := strings.Index(rest, strings.ToLower(token))
+		if i < 0 {
+			return "", false
+		}
+		rest = rest[i+len(token):]
+	}
+	return text[len(text)-len(rest):], true
+}
+
+// ttlNumbers и ttlUnits описывают слова, которыми задаётся срок жизни сообщения.
+var (
+	ttlNumbers = map[string]int{
+		"один": 1, "одну": 1, "два": 2, "две": 2, "три": 3, "четыре": 4, "пять": 5,
+		"шесть": 6, "десять": 10, "пятнадцать": 15, "двадцать": 20, "тридцать": 30,
+	}
+	ttlUnits = map[string]time.Duration{
+		"минуту": time.Minute, "минуты": time.Minute, "минут": time.Minute,
+		"час": time.Hour, "часа": time.Hour, "часов": time.Hour,
+		"день": 24 * time.Hour, "дня": 24 * time.Hour, "дней": 24 * time.Hour, "сутки": 24 * time.Hour,
+		"неделю": 7 * 24 * time.Hour, "недели": 7 * 24 * time.Hour, "недель": 7 * 24 * time.Hour,
+	}
+)
+
+// parseTTL ищет в начале текста сообщения, сразу после получателя или времени
+// доставки, срок жизни вида «на один час», «на 30 минут» или «на сутки».
+// Возвращает срок, его запись для озвучивания и текст сообщения без него.
+func parseTTL(message string) (ttl time.Duration, phrase string, rest string, ok bool) {
+	// срок в другом месте текста принимается за обычные слова сообщения:
+	// «заскочу к тебе на минуту»
+	words := strings.Fields(message)
+	if len(words) < 2 || words[0] != "на" {
+		return 0, "", message, false
+	}
+
+	count, consumed := 1, 1
+	next := strings.Trim(words[1], ":,.")
+	if n, err := strconv.Atoi(next); err == nil {
+		count, consumed = n, 2
+	} else if n, found := ttlNumbers[strings.ToLower(next)]; found {
+		count, consumed = n, 2
+	}
+	if consumed >= len(words) {
+		return 0, "", message, false
+	}
+
+	unit, found := ttlUnits[strings.ToLower(strings.Trim(words[consumed], ":,."))]
+	if !found || count <= 0 {
+		return 0, "", message, false
+	}
+
+	end := consumed + 1
+	phrase = strings.Trim(strings.Join(words[1:end], " "), ":,.")
+	rest = strings.TrimLeft(strings.Join(words[end:], " "), ":, ")
+	return time.Duration(count) * unit, phrase, rest, true
+}
+
+// parseReceiptCommand разбирает команду вида "Прочитал ли <имя> моё сообщение"
+// и возвращает имя получателя.
+func parseReceiptCommand(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) < 3 {
+		return ""
+	}
+	return strings.Trim(fields[2], "?,.")
+}
+
+// parseSearchCommand разбирает команду вида
+// "Найди сообщения от <имя> про <тему>" и возвращает имя отправителя и тему.
+// Обе части необязательны и могут идти в любом порядке.
+func parseSearchCommand(command string) (sender string, topic string) {
+	fields := strings.Fields(command)
+
+	var topicWords []string
+	inTopic := false
+	for i := 2; i < len(fields); i++ {
+		word := strings.Trim(fields[i], "?,.")
+		switch {
+		case word == "от" && i+1 < len(fields):
+			sender = strings.Trim(fields[i+1], "?,.")
+			inTopic = false
+			i++
+		case word == "про" || word == "о" || word == "об":
+			inTopic = true
+		case inTopic:
+			topicWords = append(topicWords, word)
+		}
+	}
+	return sender, strings.Join(topicWords, " ")
+}
+
+// parseRenameCommand разбирает команду вида "Смени имя на <username>"
+// и возвращает новое имя пользователя.
+func parseRenameCommand(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) < 4 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// parseCreateGroupCommand разбирает команду вида "Создай группу <название>"
+// и возвращает название группы.
+func parseCreateGroupCommand(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) < 3 {
+		return ""
+	}
+	return strings.Join(fields[2:], " ")
+}
+
+// parseGroupMemberCommand разбирает команды вида
+// "Добавь <username> в группу <название>" и "Удали <username> из группы <название>"
+// и возвращает имя участника и название группы.
+func parseGroupMemberCommand(command string) (username string, group string) {
+	fields := strings.Fields(command)
+	if len(fields) < 5 {
+		return "", ""
+	}
+	return fields[1], strings.Join(fields[4:], " ")
+}
+
+// parseSaveContactCommand разбирает команду вида
+// "Сохрани контакт <username> как <псевдоним>" и возвращает имя пользователя
+// и нормализованный псевдоним.
+func parseSaveContactCommand(command string) (username string, alias string) {
+	fields := strings.Fields(command)
+	if len(fields) < 5 || fields[3] != "как" {
+		return "", ""
+	}
+	return fields[2], resolver.Normalize(strings.Join(fields[4:], " "))
+}
+
+// parseRemoveContactCommand разбирает команду вида "Удали контакт <псевдоним>"
+// и возвращает нормализованный псевдоним.
+func parseRemoveContactCommand(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) < 3 {
+		return ""
+	}
+	return resolver.Normalize(strings.Join(fields[2:], " "))
+}
+
+// parseBlockCommand разбирает команды вида "Заблокируй <имя>" и "Разблокируй <имя>"
+// и возвращает имя пользователя или псевдоним.
+func parseBlockCommand(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) < 2 {
+		return ""
+	}
+	return strings.Join(fields[1:], " ")
+}
+
+// parseFIONames возвращает имена и фамилии из сущностей YANDEX.FIO команды.
+// Алиса приводит их к именительному падежу, поэтому они служат подсказками
+// для поиска получателя.
+func parseFIONames(nlu models.Nlu) []string {
+	var names []string
+	for _, entity := range nlu.Entities {
+		if entity.Type != models.EntityFIO {
+			continue
+		}
+		var fio models.FIO
+		if err := json.Unmarshal(entity.Value, &fio); err != nil {
+			continue
+		}
+		for _, name := range []string{fio.FirstName, fio.LastName} {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// chooseCandidate возвращает кандидата, которого пользователь назвал
+// в ответ на уточняющий вопрос, или пустую строку.
+func chooseCandidate(command string, candidates []string) string {
+	for _, candidate := range candidates {
+		if resolver.Normalize(command) == resolver.Normalize(candidate) {
+			return candidate
+		}
+	}
+	if matches := resolver.Match([]string{command}, candidates); len(matches) == 1 {
+		return matches[0]
+	}
+	return ""
+}
+
+// isConfirmation сообщает, согласился ли пользователь в ответ на вопрос навыка.
+func isConfirmation(utterance models.SimpleUtterance) bool {
+	if _, ok := utterance.Nlu.Intents[models.IntentConfirm]; ok {
+		return true
+	}
+	switch resolver.Normalize(utterance.Command) {
+	case "да", "подтверждаю", "да, удали":
+		return true
+	}
+	return false
+}
+
+// isRejection сообщает, отказался ли пользователь в ответ на вопрос навыка.
+func isRejection(utterance models.SimpleUtterance) bool {
+	if _, ok := utterance.Nlu.Intents[models.IntentReject]; ok {
+		return true
+	}
+	switch resolver.Normalize(utterance.Command) {
+	case "нет", "отмена", "не надо":
+		return true
+	}
+	return false
+}
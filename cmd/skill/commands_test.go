@@ -0,0 +1,142 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSendCommand(t *testing.T) {
+	testCases := []struct {
+		command          string
+		expectedUsername string
+		expectedMessage  string
+	}{
+		{command: "Отправь Ивану привет, как дела?", expectedUsername: "Ивану", expectedMessage: "привет, как дела?"},
+		{command: "Отправь Ивану", expectedUsername: "Ивану", expectedMessage: ""},
+		{command: "Отправь", expectedUsername: "", expectedMessage: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			username, message := parseSendCommand(tc.command)
+			assert.Equal(t, tc.expectedUsername, username)
+			assert.Equal(t, tc.expectedMessage, message)
+		})
+	}
+}
+
+func TestParseReadCommand(t *testing.T) {
+	assert.Equal(t, 1, parseReadCommand("Прочитай 2"))
+	assert.Equal(t, 0, parseReadCommand("Прочитай"))
+	assert.Equal(t, 0, parseReadCommand("Прочитай первое"))
+}
+
+func TestParseReplyCommand(t *testing.T) {
+	assert.Equal(t, "буду в семь", parseReplyCommand("Ответь: буду в семь"))
+	assert.Equal(t, "буду в семь", parseReplyCommand("Ответь буду в семь"))
+	assert.Equal(t, "", parseReplyCommand("Ответь"))
+}
+
+func TestParseGroupMemberCommand(t *testing.T) {
+	username, group := parseGroupMemberCommand("Добавь Ивана в группу семья")
+	assert.Equal(t, "Ивана", username)
+	assert.Equal(t, "семья", group)
+
+	username, group = parseGroupMemberCommand("Удали Ивана из группы")
+	assert.Equal(t, "", username)
+	assert.Equal(t, "", group)
+}
+
+func TestStripDateTime(t *testing.T) {
+	testCases := []struct {
+		command  string
+		tokens   []string
+		entity   models.EntityTokens
+		expected string
+	}{
+		{
+			command:  "Отправь Маше завтра в 18:00 купить хлеб",
+			tokens:   []string{"отправь", "маше", "завтра", "в", "18", "00", "купить", "хлеб"},
+			entity:   models.EntityTokens{Start: 2, End: 6},
+			expected: "купить хлеб",
+		},
+		{
+			command:  "Отправь Маше завтра в 18:00: купить хлеб, молоко",
+			tokens:   []string{"отправь", "маше", "завтра", "в", "18", "00", "купить", "хлеб", "молоко"},
+			entity:   models.EntityTokens{Start: 2, End: 6},
+			expected: "купить хлеб, молоко",
+		},
+		{
+			command:  "Отправь Маше завтра в девять утра: не забудь ключи",
+			tokens:   []string{"отправь", "маше", "завтра", "в", "девять", "утра", "не", "забудь", "ключи"},
+			entity:   models.EntityTokens{Start: 2, End: 6},
+			expected: "не забудь ключи",
+		},
+		{
+			command:  "Отправь Маше завтра встречаемся в 10:30",
+			tokens:   []string{"отправь", "маше", "завтра", "встречаемся", "в", "10", "30"},
+			entity:   models.EntityTokens{Start: 2, End: 3},
+			expected: "встречаемся в 10 30",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			_, message := parseSendCommand(tc.command)
+			assert.Equal(t, tc.expected, stripDateTime(message, models.Nlu{Tokens: tc.tokens}, tc.entity))
+		})
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	testCases := []struct {
+		message string
+		ok      bool
+		ttl     time.Duration
+		phrase  string
+		rest    string
+	}{
+		{message: "на один час: код от домофона 1234", ok: true, ttl: time.Hour, phrase: "один час", rest: "код от домофона 1234"},
+		{message: "на 30 минут код от домофона 1234", ok: true, ttl: 30 * time.Minute, phrase: "30 минут", rest: "код от домофона 1234"},
+		{message: "код от домофона 1234 на 30 минут", ok: false, rest: "код от домофона 1234 на 30 минут"},
+		{message: "заскочу к тебе на минуту", ok: false, rest: "заскочу к тебе на минуту"},
+		{message: "буду через час, давай на час", ok: false, rest: "буду через час, давай на час"},
+		{message: "на сутки уехал на дачу", ok: true, ttl: 24 * time.Hour, phrase: "сутки", rest: "уехал на дачу"},
+		{message: "встречаемся на час позже", ok: false, rest: "встречаемся на час позже"},
+		{message: "привет", ok: false, rest: "привет"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.message, func(t *testing.T) {
+			ttl, phrase, rest, ok := parseTTL(tc.message)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.ttl, ttl)
+			assert.Equal(t, tc.phrase, phrase)
+			assert.Equal(t, tc.rest, rest)
+		})
+	}
+}
+
+func TestParseSearchCommand(t *testing.T) {
+	testCases := []struct {
+		command        string
+		expectedSender string
+		expectedTopic  string
+	}{
+		{command: "Найди сообщения от Оли про билеты", expectedSender: "Оли", expectedTopic: "билеты"},
+		{command: "Найди сообщения про билеты в театр от Оли", expectedSender: "Оли", expectedTopic: "билеты в театр"},
+		{command: "Найди сообщения от Оли", expectedSender: "Оли", expectedTopic: ""},
+		{command: "Найди сообщения о поездке", expectedSender: "", expectedTopic: "поездке"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			sender, topic := parseSearchCommand(tc.command)
+			assert.Equal(t, tc.expectedSender, sender)
+			assert.Equal(t, tc.expectedTopic, topic)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/models"
+)
+
+// defaultDeliveryHour задаёт час доставки, если пользователь назвал только день.
+const defaultDeliveryHour = 9
+
+// parseDateTime находит в команде первую сущность YANDEX.DATETIME и вычисляет
+// момент, который она обозначает, относительно now в часовом поясе now.
+// Вместе с моментом возвращается положение сущности среди Nlu.Tokens.
+func parseDateTime(nlu models.Nlu, now time.Time) (time.Time, models.EntityTokens, bool) {
+	for _, entity := range nlu.Entities {
+		if entity.Type != models.EntityDateTime {
+			continue
+		}
+		var dt models.DateTime
+		if err := json.Unmarshal(entity.Value, &dt); err != nil {
+			continue
+		}
+		return resolveDateTime(dt, now), entity.Tokens, true
+	}
+	return time.Time{}, models.EntityTokens{}, false
+}
+
+// resolveDateTime переводит значение YANDEX.DATETIME в момент времени.
+// Абсолютное время без даты, которое уже прошло сегодня, переносится на завтра.
+func resolveDateTime(dt models.DateTime, now time.Time) time.Time {
+	year, month, day := now.Date()
+	hour, minute := now.Hour(), now.Minute()
+
+	apply := func(value *int, relative bool, current int) (int, bool) {
+		switch {
+		case value == nil:
+			return current, false
+		case relative:
+			return current + *value, true
+		default:
+			return *value, true
+		}
+	}
+
+	year, hasYear := apply(dt.Year, dt.YearIsRelative, year)
+	m, hasMonth := apply(dt.Month, dt.MonthIsRelative, int(month))
+	day, hasDay := apply(dt.Day, dt.DayIsRelative, day)
+	hour, hasHour := apply(dt.Hour, dt.HourIsRelative, hour)
+	minute, hasMinute := apply(dt.Minute, dt.MinuteIsRelative, minute)
+
+	hasDate := hasYear || hasMonth || hasDay
+	switch {
+	case !hasHour && !hasMinute && hasDate:
+		// назван только день: доставим утром
+		hour, minute = defaultDeliveryHour, 0
+	case hasHour && !hasMinute && !dt.HourIsRelative:
+		// «в девять» означает ровно девять часов
+		minute = 0
+	}
+
+	t := time.Date(year, time.Month(m), day, hour, minute, 0, 0, now.Location())
+	if !hasDate && !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveDateTime(t *testing.T) {
+	tz, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	now := time.Date(2024, time.March, 10, 14, 30, 0, 0, tz)
+
+	one, nine, twenty := 1, 9, 20
+
+	testCases := []struct {
+		name     string
+		value    models.DateTime
+		expected time.Time
+	}{
+		{
+			name:     "tomorrow_at_nine",
+			value:    models.DateTime{Day: &one, DayIsRelative: true, Hour: &nine},
+			expected: time.Date(2024, time.March, 11, 9, 0, 0, 0, tz),
+		},
+		{
+			name:     "tomorrow",
+			value:    models.DateTime{Day: &one, DayIsRelative: true},
+			expected: time.Date(2024, time.March, 11, 9, 0, 0, 0, tz),
+		},
+		{
+			name:     "passed_hour_today",
+			value:    models.DateTime{Hour: &nine},
+			expected: time.Date(2024, time.March, 11, 9, 0, 0, 0, tz),
+		},
+		{
+			name:     "in_one_hour",
+			value:    models.DateTime{Hour: &one, HourIsRelative: true},
+			expected: time.Date(2024, time.March, 10, 15, 30, 0, 0, tz),
+		},
+		{
+			name:     "absolute_date",
+			value:    models.DateTime{Month: &nine, Day: &twenty},
+			expected: time.Date(2024, time.September, 20, 9, 0, 0, 0, tz),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, resolveDateTime(tc.value, now))
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/logger"
+	"github.com/VladimirAzanza/alisa_skill/internal/metrics"
+	"github.com/VladimirAzanza/alisa_skill/internal/models"
+	"go.uber.org/zap"
+)
+
+// fallbackText произносится, если навык не успел ответить вовремя. Сообщение
+// к этому моменту обычно уже поставлено в очередь, поэтому пользователя
+// не просят повторить команду: повтор отправил бы его второй раз.
+const fallbackText = "Извините, не успела ответить. Если вы отправляли сообщение, повторять его не нужно: скорее всего, оно уже в пути."
+
+// deadlineOverruns считает запросы, на которые вместо ответа ушла заглушка.
+var deadlineOverruns = metrics.Default.NewCounterVec("skill_deadline_overruns_total",
+	"Requests answered with the fallback because the handler overran its budget.")
+
+// fallbackMiddleware ждёт ответа обработчика не дольше budget. Если обработчик
+// не успел, клиенту уходит ответ с fallbackText, а контекст обработчика
+// отменяется; всё, что обработчик напишет позже, отбрасывается.
+func fallbackMiddleware(budget time.Duration, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if budget <= 0 {
+			h(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), budget)
+		defer cancel()
+
+		bw := &bufferedWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+		start := time.Now()
+
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			h(bw, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			bw.flush(w)
+		case <-ctx.Done():
+			// обработчик мог закончить одновременно с истечением срока
+			select {
+			case <-done:
+				bw.flush(w)
+				return
+			default:
+			}
+
+			bw.timeout()
+			deadlineOverruns.With().Inc()
+			logger.FromContext(r.Context()).Warn("handler exceeded response budget",
+				zap.String("path", r.URL.Path),
+				zap.Duration("budget", budget),
+				zap.Duration("elapsed", time.Since(start)),
+			)
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(models.Response{
+				Response: models.ResponsePayload{Text: fallbackText},
+				Version:  "1.0",
+			})
+		}
+	}
+}
+
+// bufferedWriter накапливает ответ обработчика, пока не станет ясно,
+// успел ли он уложиться в отведённое время.
+type bufferedWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	timedOut bool
+}
+
+func (b *bufferedWriter) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedWriter) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *bufferedWriter) WriteHeader(statusCode int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.timedOut || b.status != 0 {
+		return
+	}
+	b.status = statusCode
+}
+
+// timeout запрещает дальнейшую запись: ответ обработчика уже не нужен.
+func (b *bufferedWriter) timeout() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timedOut = true
+}
+
+// flush передаёт накопленный ответ клиенту.
+func (b *bufferedWriter) flush(w http.ResponseWriter) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, values := range b.header {
+		w.Header()[key] = values
+	}
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallbackMiddleware(t *testing.T) {
+	t.Run("in_time", func(t *testing.T) {
+		handler := fallbackMiddleware(time.Second, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"ok": true}`))
+		})
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "/", nil))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, `{"ok": true}`, w.Body.String())
+	})
+
+	t.Run("overrun", func(t *testing.T) {
+		cancelled := make(chan struct{})
+		handler := fallbackMiddleware(50*time.Millisecond, func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			close(cancelled)
+			_, err := w.Write([]byte(`{"late": true}`))
+			assert.ErrorIs(t, err, http.ErrHandlerTimeout)
+		})
+
+		overruns := deadlineOverruns.With().Value()
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodPost, "/", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp models.Response
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, fallbackText, resp.Response.Text)
+		assert.Equal(t, overruns+1, deadlineOverruns.With().Value())
+
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Fatal("handler context was not cancelled")
+		}
+	})
+}
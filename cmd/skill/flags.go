@@ -1,14 +1,67 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/moderation"
+	"github.com/VladimirAzanza/alisa_skill/internal/oauth"
+	"github.com/VladimirAzanza/alisa_skill/internal/username"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 var (
-	flagRunAddr     string
-	flagLogLevel    string
-	flagDatabaseURI string
+	flagRunAddr             string
+	flagLogLevel            string
+	flagDatabaseURI         string
+	flagUsernameMinLength   int
+	flagUsernameMaxLength   int
+	flagReservedNames       string
+	flagRetentionRead       time.Duration
+	flagRetentionUnread     time.Duration
+	flagRetentionArchive    bool
+	flagPurgeInterval       time.Duration
+	flagPurgeBatchSize      int
+	flagAnnounceReceipts    bool
+	flagRateWindow          time.Duration
+	flagSenderRate          int
+	flagRecepientRate       int
+	flagDuplicateLimit      int
+	flagDuplicateWindow     time.Duration
+	flagMaxPayload          int
+	flagProfanityFilter     string
+	flagModerationURL       string
+	flagModerationTimeout   time.Duration
+	flagSkillIDs            string
+	flagWebhookSecret       string
+	flagWebhookHMACKey      string
+	flagInsecureWebhook     bool
+	flagSkillsConfig        string
+	flagOAuthClientID       string
+	flagOAuthClientSecret   string
+	flagOAuthRedirectURIs   string
+	flagOAuthDirectory      string
+	flagReadTimeout         time.Duration
+	flagWriteTimeout        time.Duration
+	flagIdleTimeout         time.Duration
+	flagMaxHeaderBytes      int
+	flagMaxBodyBytes        int64
+	flagRequestTimeout      time.Duration
+	flagResponseBudget      time.Duration
+	flagWebhookPath         string
+	flagReadyQueueThreshold int
+	flagTraceExporter       string
+	flagOTLPEndpoint        string
+	flagServiceName         string
 )
 
 // parseFlags initializes and parses command-line flags for the application.
@@ -19,6 +72,45 @@ func parseFlags() {
 	flag.StringVar(&flagRunAddr, "a", ":8080", "address and port to run server")
 	flag.StringVar(&flagLogLevel, "l", "debug", "log level")
 	flag.StringVar(&flagDatabaseURI, "d", "", "database URI")
+	flag.IntVar(&flagUsernameMinLength, "username-min", 2, "minimum username length")
+	flag.IntVar(&flagUsernameMaxLength, "username-max", 32, "maximum username length")
+	flag.StringVar(&flagReservedNames, "reserved-names", "", "comma-separated list of additional reserved usernames")
+	flag.DurationVar(&flagRetentionRead, "retention-read", 0, "how long to keep read messages, 0 keeps them forever")
+	flag.DurationVar(&flagRetentionUnread, "retention-unread", 0, "how long to keep unread messages, 0 keeps them forever")
+	flag.BoolVar(&flagRetentionArchive, "retention-archive", false, "move expired messages to the archive table instead of deleting them")
+	flag.DurationVar(&flagPurgeInterval, "purge-interval", time.Minute, "how often to purge expired messages, 0 disables purging")
+	flag.IntVar(&flagPurgeBatchSize, "purge-batch", 500, "maximum number of messages purged by one query")
+	flag.BoolVar(&flagAnnounceReceipts, "announce-receipts", true, "announce read receipts to senders at session start")
+	flag.DurationVar(&flagRateWindow, "rate-window", time.Minute, "window for sender and recipient rate limits")
+	flag.IntVar(&flagSenderRate, "sender-rate", 20, "maximum number of messages one user can send per window, 0 disables the limit")
+	flag.IntVar(&flagRecepientRate, "recipient-rate", 50, "maximum number of messages one user can receive per window, 0 disables the limit")
+	flag.IntVar(&flagDuplicateLimit, "duplicate-limit", 3, "how many times the same text can be sent per duplicate window, 0 disables the check")
+	flag.DurationVar(&flagDuplicateWindow, "duplicate-window", 10*time.Minute, "window for duplicate message detection")
+	flag.IntVar(&flagMaxPayload, "max-payload", 500, "maximum message length in characters, 0 disables the limit")
+	flag.StringVar(&flagProfanityFilter, "profanity-filter", "mask", "built-in profanity filter mode: mask, reject or off")
+	flag.StringVar(&flagModerationURL, "moderation-url", "", "URL of an external moderation service, empty disables it")
+	flag.DurationVar(&flagModerationTimeout, "moderation-timeout", time.Second, "timeout for the external moderation service")
+	flag.StringVar(&flagSkillIDs, "skill-ids", "", "comma-separated list of skill IDs allowed to call the webhook, empty allows any")
+	flag.StringVar(&flagWebhookSecret, "webhook-secret", "", "shared secret expected in the X-Skill-Secret header")
+	flag.StringVar(&flagWebhookHMACKey, "webhook-hmac-key", "", "key for the HMAC-SHA256 body signature in the X-Skill-Signature header")
+	flag.BoolVar(&flagInsecureWebhook, "insecure-webhook", false, "allow starting without a skill ID allowlist, secret or signature key, for development only")
+	flag.StringVar(&flagSkillsConfig, "skills", "", "path to a JSON file with the ID, path, schema, timezone and disabled commands of each skill served by one process")
+	flag.StringVar(&flagOAuthClientID, "oauth-client-id", "", "client ID for account linking, empty disables the built-in authorization server")
+	flag.StringVar(&flagOAuthClientSecret, "oauth-client-secret", "", "client secret for account linking")
+	flag.StringVar(&flagOAuthRedirectURIs, "oauth-redirect-uris", "https://social.yandex.net/broker/redirect", "comma-separated list of allowed OAuth redirect URIs")
+	flag.StringVar(&flagOAuthDirectory, "oauth-directory", "", "path to a JSON object mapping directory logins to passwords")
+	flag.DurationVar(&flagReadTimeout, "read-timeout", 5*time.Second, "maximum duration for reading a request")
+	flag.DurationVar(&flagWriteTimeout, "write-timeout", 10*time.Second, "maximum duration before timing out writes of a response")
+	flag.DurationVar(&flagIdleTimeout, "idle-timeout", time.Minute, "how long to keep idle keep-alive connections")
+	flag.IntVar(&flagMaxHeaderBytes, "max-header-bytes", 16<<10, "maximum size of request headers")
+	flag.Int64Var(&flagMaxBodyBytes, "max-body-bytes", 64<<10, "maximum size of a request body, compressed or not")
+	flag.DurationVar(&flagRequestTimeout, "request-timeout", 3*time.Second, "deadline for handling one webhook request")
+	flag.DurationVar(&flagResponseBudget, "response-budget", 2500*time.Millisecond, "time after which a spoken fallback is returned instead of the response, 0 disables it")
+	flag.StringVar(&flagWebhookPath, "webhook-path", "/webhook", "path of the webhook, the root path is served too")
+	flag.IntVar(&flagReadyQueueThreshold, "ready-queue-threshold", 768, "outgoing queue length at which the service reports not ready, 0 disables the check")
+	flag.StringVar(&flagTraceExporter, "trace-exporter", "none", "span exporter: otlp, stdout or none")
+	flag.StringVar(&flagOTLPEndpoint, "otlp-endpoint", "http://localhost:4318", "base URL of the OTLP/HTTP collector")
+	flag.StringVar(&flagServiceName, "service-name", "alisa-skill", "service name reported with spans")
 	flag.Parse()
 
 	if envRunAddr := os.Getenv("RUN_ADDR"); envRunAddr != "" {
@@ -30,4 +122,225 @@ func parseFlags() {
 	if envDatabaseURI := os.Getenv("DATABASE_URI"); envDatabaseURI != "" {
 		flagDatabaseURI = envDatabaseURI
 	}
+	if envMinLength, err := strconv.Atoi(os.Getenv("USERNAME_MIN_LENGTH")); err == nil {
+		flagUsernameMinLength = envMinLength
+	}
+	if envMaxLength, err := strconv.Atoi(os.Getenv("USERNAME_MAX_LENGTH")); err == nil {
+		flagUsernameMaxLength = envMaxLength
+	}
+	if envReservedNames := os.Getenv("RESERVED_NAMES"); envReservedNames != "" {
+		flagReservedNames = envReservedNames
+	}
+	if envRetentionRead, err := time.ParseDuration(os.Getenv("RETENTION_READ")); err == nil {
+		flagRetentionRead = envRetentionRead
+	}
+	if envRetentionUnread, err := time.ParseDuration(os.Getenv("RETENTION_UNREAD")); err == nil {
+		flagRetentionUnread = envRetentionUnread
+	}
+	if envRetentionArchive, err := strconv.ParseBool(os.Getenv("RETENTION_ARCHIVE")); err == nil {
+		flagRetentionArchive = envRetentionArchive
+	}
+	if envPurgeInterval, err := time.ParseDuration(os.Getenv("PURGE_INTERVAL")); err == nil {
+		flagPurgeInterval = envPurgeInterval
+	}
+	if envPurgeBatchSize, err := strconv.Atoi(os.Getenv("PURGE_BATCH")); err == nil {
+		flagPurgeBatchSize = envPurgeBatchSize
+	}
+	if envAnnounceReceipts, err := strconv.ParseBool(os.Getenv("ANNOUNCE_RECEIPTS")); err == nil {
+		flagAnnounceReceipts = envAnnounceReceipts
+	}
+	if envRateWindow, err := time.ParseDuration(os.Getenv("RATE_WINDOW")); err == nil {
+		flagRateWindow = envRateWindow
+	}
+	if envSenderRate, err := strconv.Atoi(os.Getenv("SENDER_RATE")); err == nil {
+		flagSenderRate = envSenderRate
+	}
+	if envRecepientRate, err := strconv.Atoi(os.Getenv("RECIPIENT_RATE")); err == nil {
+		flagRecepientRate = envRecepientRate
+	}
+	if envDuplicateLimit, err := strconv.Atoi(os.Getenv("DUPLICATE_LIMIT")); err == nil {
+		flagDuplicateLimit = envDuplicateLimit
+	}
+	if envDuplicateWindow, err := time.ParseDuration(os.Getenv("DUPLICATE_WINDOW")); err == nil {
+		flagDuplicateWindow = envDuplicateWindow
+	}
+	if envMaxPayload, err := strconv.Atoi(os.Getenv("MAX_PAYLOAD")); err == nil {
+		flagMaxPayload = envMaxPayload
+	}
+	if envProfanityFilter := os.Getenv("PROFANITY_FILTER"); envProfanityFilter != "" {
+		flagProfanityFilter = envProfanityFilter
+	}
+	if envModerationURL := os.Getenv("MODERATION_URL"); envModerationURL != "" {
+		flagModerationURL = envModerationURL
+	}
+	if envModerationTimeout, err := time.ParseDuration(os.Getenv("MODERATION_TIMEOUT")); err == nil {
+		flagModerationTimeout = envModerationTimeout
+	}
+	if envSkillIDs := os.Getenv("SKILL_IDS"); envSkillIDs != "" {
+		flagSkillIDs = envSkillIDs
+	}
+	if envWebhookSecret := os.Getenv("WEBHOOK_SECRET"); envWebhookSecret != "" {
+		flagWebhookSecret = envWebhookSecret
+	}
+	if envWebhookHMACKey := os.Getenv("WEBHOOK_HMAC_KEY"); envWebhookHMACKey != "" {
+		flagWebhookHMACKey = envWebhookHMACKey
+	}
+	if envInsecureWebhook, err := strconv.ParseBool(os.Getenv("INSECURE_WEBHOOK")); err == nil {
+		flagInsecureWebhook = envInsecureWebhook
+	}
+	if envSkillsConfig := os.Getenv("SKILLS_CONFIG"); envSkillsConfig != "" {
+		flagSkillsConfig = envSkillsConfig
+	}
+	if envOAuthClientID := os.Getenv("OAUTH_CLIENT_ID"); envOAuthClientID != "" {
+		flagOAuthClientID = envOAuthClientID
+	}
+	if envOAuthClientSecret := os.Getenv("OAUTH_CLIENT_SECRET"); envOAuthClientSecret != "" {
+		flagOAuthClientSecret = envOAuthClientSecret
+	}
+	if envOAuthRedirectURIs := os.Getenv("OAUTH_REDIRECT_URIS"); envOAuthRedirectURIs != "" {
+		flagOAuthRedirectURIs = envOAuthRedirectURIs
+	}
+	if envOAuthDirectory := os.Getenv("OAUTH_DIRECTORY"); envOAuthDirectory != "" {
+		flagOAuthDirectory = envOAuthDirectory
+	}
+	if envReadTimeout, err := time.ParseDuration(os.Getenv("READ_TIMEOUT")); err == nil {
+		flagReadTimeout = envReadTimeout
+	}
+	if envWriteTimeout, err := time.ParseDuration(os.Getenv("WRITE_TIMEOUT")); err == nil {
+		flagWriteTimeout = envWriteTimeout
+	}
+	if envIdleTimeout, err := time.ParseDuration(os.Getenv("IDLE_TIMEOUT")); err == nil {
+		flagIdleTimeout = envIdleTimeout
+	}
+	if envMaxHeaderBytes, err := strconv.Atoi(os.Getenv("MAX_HEADER_BYTES")); err == nil {
+		flagMaxHeaderBytes = envMaxHeaderBytes
+	}
+	if envMaxBodyBytes, err := strconv.ParseInt(os.Getenv("MAX_BODY_BYTES"), 10, 64); err == nil {
+		flagMaxBodyBytes = envMaxBodyBytes
+	}
+	if envRequestTimeout, err := time.ParseDuration(os.Getenv("REQUEST_TIMEOUT")); err == nil {
+		flagRequestTimeout = envRequestTimeout
+	}
+	if envResponseBudget, err := time.ParseDuration(os.Getenv("RESPONSE_BUDGET")); err == nil {
+		flagResponseBudget = envResponseBudget
+	}
+	if envWebhookPath := os.Getenv("WEBHOOK_PATH"); envWebhookPath != "" {
+		flagWebhookPath = envWebhookPath
+	}
+	if envReadyQueueThreshold, err := strconv.Atoi(os.Getenv("READY_QUEUE_THRESHOLD")); err == nil {
+		flagReadyQueueThreshold = envReadyQueueThreshold
+	}
+	if envTraceExporter := os.Getenv("TRACE_EXPORTER"); envTraceExporter != "" {
+		flagTraceExporter = envTraceExporter
+	}
+	// переменные окружения OpenTelemetry понимают и стандартные SDK
+	if envOTLPEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); envOTLPEndpoint != "" {
+		flagOTLPEndpoint = envOTLPEndpoint
+	}
+	if envServiceName := os.Getenv("OTEL_SERVICE_NAME"); envServiceName != "" {
+		flagServiceName = envServiceName
+	}
+}
+
+// usernamePolicy собирает правила проверки имён из флагов.
+func usernamePolicy() username.Policy {
+	policy := username.DefaultPolicy()
+	policy.MinLength = flagUsernameMinLength
+	policy.MaxLength = flagUsernameMaxLength
+	for _, name := range strings.Split(flagReservedNames, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			policy.Reserved = append(policy.Reserved, name)
+		}
+	}
+	return policy
+}
+
+// messageModerator собирает проверку сообщений из флагов: сначала встроенный
+// словарный фильтр, затем внешний сервис. Возвращает nil, если оба отключены.
+func messageModerator() (moderation.Moderator, error) {
+	var chain moderation.Chain
+	switch flagProfanityFilter {
+	case "mask":
+		chain = append(chain, moderation.Dictionary{Words: moderation.DefaultWords})
+	case "reject":
+		chain = append(chain, moderation.Dictionary{Words: moderation.DefaultWords, Reject: true})
+	case "off":
+	default:
+		return nil, fmt.Errorf("unknown profanity filter mode %q", flagProfanityFilter)
+	}
+	if flagModerationURL != "" {
+		chain = append(chain, moderation.HTTP{
+			URL:    flagModerationURL,
+			Client: &http.Client{Timeout: flagModerationTimeout},
+		})
+	}
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+// spanExporter выбирает экспортёр спанов. Возвращает nil, если трассировка выключена.
+func spanExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	switch flagTraceExporter {
+	case "otlp":
+		return otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(strings.TrimSuffix(flagOTLPEndpoint, "/")+"/v1/traces"))
+	case "stdout":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", flagTraceExporter)
+	}
+}
+
+// webhookAuth собирает проверку подлинности запросов из флагов.
+func webhookAuth() authConfig {
+	config := authConfig{
+		secret:  flagWebhookSecret,
+		hmacKey: flagWebhookHMACKey,
+	}
+	for _, id := range strings.Split(flagSkillIDs, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			config.skillIDs = append(config.skillIDs, id)
+		}
+	}
+	return config
+}
+
+// oauthDirectory читает каталог учётных записей для привязки аккаунтов.
+// Возвращает nil, если привязка аккаунтов отключена.
+func oauthDirectory() (oauth.Directory, error) {
+	if flagOAuthClientID == "" {
+		return nil, nil
+	}
+	if flagOAuthDirectory == "" {
+		return nil, fmt.Errorf("account linking requires -oauth-directory")
+	}
+
+	data, err := os.ReadFile(flagOAuthDirectory)
+	if err != nil {
+		return nil, err
+	}
+	var directory oauth.StaticDirectory
+	if err := json.Unmarshal(data, &directory); err != nil {
+		return nil, fmt.Errorf("cannot parse OAuth directory: %w", err)
+	}
+	return directory, nil
+}
+
+// oauthServer создаёт сервер авторизации, выдающий токены в хранилище tokens.
+func oauthServer(directory oauth.Directory, tokens oauth.TokenStore) *oauth.Server {
+	server := &oauth.Server{
+		ClientID:     flagOAuthClientID,
+		ClientSecret: flagOAuthClientSecret,
+		Directory:    directory,
+		Tokens:       tokens,
+	}
+	for _, uri := range strings.Split(flagOAuthRedirectURIs, ",") {
+		if uri = strings.TrimSpace(uri); uri != "" {
+			server.RedirectURIs = append(server.RedirectURIs, uri)
+		}
+	}
+	return server
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// pingTimeout ограничивает проверку доступности базы данных в /readyz.
+const pingTimeout = time.Second
+
+// flusherAlive сообщает, работает ли фоновая запись сообщений: её цикл
+// проходит хотя бы раз за flushInterval, если не завис на записи.
+func (a *app) flusherAlive(now time.Time) bool {
+	return now.Sub(time.Unix(0, a.flushBeat.Load())) < 3*flushInterval
+}
+
+// ready возвращает причину, по которой навык не может принимать запросы.
+func (a *app) ready(ctx context.Context) error {
+	if err := a.store.Ping(ctx); err != nil {
+		return fmt.Errorf("database unavailable: %w", err)
+	}
+	if !a.flusherAlive(time.Now()) {
+		return fmt.Errorf("message flusher is stuck")
+	}
+	if depth := len(a.msgChan); a.config.queueThreshold > 0 && depth >= a.config.queueThreshold {
+		return fmt.Errorf("message queue is too long: %d", depth)
+	}
+	return nil
+}
+
+// healthzHandler отвечает, пока процесс запущен.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+// livezHandler проверяет, что фоновая запись сообщений не зависла:
+// иначе процесс нужно перезапустить.
+func livezHandler(apps []*app) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, a := range apps {
+			if !a.flusherAlive(time.Now()) {
+				http.Error(w, "message flusher is stuck", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Write([]byte("ok\n"))
+	}
+}
+
+// readyzHandler проверяет, что все навыки готовы принимать запросы.
+func readyzHandler(apps []*app) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), pingTimeout)
+		defer cancel()
+
+		for _, a := range apps {
+			if err := a.ready(ctx); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Write([]byte("ok\n"))
+	}
+}
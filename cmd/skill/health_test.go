@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func TestReadyz(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewMockStore(ctrl)
+
+	newTestApp := func() *app {
+		a := &app{store: s, config: appConfig{queueThreshold: 2}, msgChan: make(chan queuedMessage, 4)}
+		a.flushBeat.Store(time.Now().UnixNano())
+		return a
+	}
+	probe := func(handler http.HandlerFunc) int {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Code
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		s.EXPECT().Ping(gomock.Any()).Return(nil)
+		assert.Equal(t, http.StatusOK, probe(readyzHandler([]*app{newTestApp()})))
+	})
+
+	t.Run("database", func(t *testing.T) {
+		s.EXPECT().Ping(gomock.Any()).Return(errors.New("connection refused"))
+		assert.Equal(t, http.StatusServiceUnavailable, probe(readyzHandler([]*app{newTestApp()})))
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		s.EXPECT().Ping(gomock.Any()).Return(nil)
+		a := newTestApp()
+		a.msgChan <- queuedMessage{}
+		a.msgChan <- queuedMessage{}
+		assert.Equal(t, http.StatusServiceUnavailable, probe(readyzHandler([]*app{a})))
+	})
+
+	t.Run("stuck_flusher", func(t *testing.T) {
+		s.EXPECT().Ping(gomock.Any()).Return(nil)
+		a := newTestApp()
+		a.flushBeat.Store(time.Now().Add(-time.Hour).UnixNano())
+		assert.Equal(t, http.StatusServiceUnavailable, probe(readyzHandler([]*app{a})))
+		assert.Equal(t, http.StatusServiceUnavailable, probe(livezHandler([]*app{a})))
+		assert.Equal(t, http.StatusOK, probe(healthzHandler))
+	})
+}
@@ -2,13 +2,23 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/VladimirAzanza/alisa_skill/internal/logger"
+	"github.com/VladimirAzanza/alisa_skill/internal/metrics"
+	"github.com/VladimirAzanza/alisa_skill/internal/store"
+	"github.com/VladimirAzanza/alisa_skill/internal/store/instrumented"
 	"github.com/VladimirAzanza/alisa_skill/internal/store/pg"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
 )
 
@@ -61,11 +71,172 @@ func run() error {
 
 	logger.Log.Info("Running server", zap.String("address", flagRunAddr))
 
-	conn, err := sql.Open("pgx", flagDatabaseURI)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Log.Debug("cannot export spans", zap.Error(err))
+	}))
+	tp, err := tracerProvider(ctx)
+	if err != nil {
+		return err
+	}
+	if tp != nil {
+		otel.SetTracerProvider(tp)
+		// отправим накопленные спаны перед выходом
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := tp.Shutdown(ctx); err != nil {
+				logger.Log.Warn("cannot flush spans", zap.Error(err))
+			}
+		}()
+	}
+
+	moderator, err := messageModerator()
+	if err != nil {
+		return err
+	}
+
+	config := appConfig{
+		usernames: usernamePolicy(),
+		retention: retentionConfig{
+			policy: store.RetentionPolicy{
+				Read:    flagRetentionRead,
+				Unread:  flagRetentionUnread,
+				Archive: flagRetentionArchive,
+			},
+			interval:  flagPurgeInterval,
+			batchSize: flagPurgeBatchSize,
+		},
+		announceReceipts: flagAnnounceReceipts,
+		limits: limitsConfig{
+			window:          flagRateWindow,
+			senderRate:      flagSenderRate,
+			recepientRate:   flagRecepientRate,
+			duplicates:      flagDuplicateLimit,
+			duplicateWindow: flagDuplicateWindow,
+			maxPayload:      flagMaxPayload,
+		},
+		moderator: moderator,
+	}
+
+	directory, err := oauthDirectory()
 	if err != nil {
 		return err
 	}
+	config.accountLinking = directory != nil
+	config.queueThreshold = flagReadyQueueThreshold
+
+	auth := webhookAuth()
+	mux := http.NewServeMux()
+
+	var webhook http.HandlerFunc
+	var apps []*app
+	if flagSkillsConfig == "" {
+		conn, err := sql.Open("pgx", flagDatabaseURI)
+		if err != nil {
+			return err
+		}
+		pgStore := pg.NewStore(conn)
+		if err := pgStore.Bootstrap(context.Background()); err != nil {
+			return err
+		}
+		appInstance := newApp(instrumented.New(pgStore, storeHook), config)
+		webhook = appInstance.webhook
+		apps = append(apps, appInstance)
+		if directory != nil {
+			server := oauthServer(directory, appInstance.store)
+			mux.HandleFunc("/oauth/authorize", server.Authorize)
+			mux.HandleFunc("/oauth/token", server.Token)
+		}
+	} else {
+		skills, err := loadSkills(flagSkillsConfig)
+		if err != nil {
+			return err
+		}
+		router, skillApps, err := newSkillRouter(flagDatabaseURI, config, skills)
+		if err != nil {
+			return err
+		}
+		webhook = router.ServeHTTP
+		// токены каждого навыка хранятся в его схеме
+		for id, appInstance := range skillApps {
+			apps = append(apps, appInstance)
+			if directory != nil {
+				server := oauthServer(directory, appInstance.store)
+				mux.HandleFunc("/oauth/"+id+"/authorize", server.Authorize)
+				mux.HandleFunc("/oauth/"+id+"/token", server.Token)
+			}
+		}
+		// без явного списка принимаем запросы только описанных навыков
+		if len(auth.skillIDs) == 0 {
+			for _, skill := range skills {
+				auth.skillIDs = append(auth.skillIDs, skill.ID)
+			}
+		}
+	}
+
+	if err := requireAuth(auth, flagInsecureWebhook); err != nil {
+		return err
+	}
+	if len(auth.skillIDs) == 0 {
+		logger.Log.Warn("skill ID allowlist is empty, webhook accepts requests from any skill")
+	}
+
+	registerQueueMetrics(apps)
+	mux.Handle("/metrics", metrics.Default.Handler())
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/livez", livezHandler(apps))
+	mux.HandleFunc("/readyz", readyzHandler(apps))
+	server := serverConfig{
+		readTimeout:    flagReadTimeout,
+		writeTimeout:   flagWriteTimeout,
+		idleTimeout:    flagIdleTimeout,
+		maxHeaderBytes: flagMaxHeaderBytes,
+		maxBodyBytes:   flagMaxBodyBytes,
+		requestTimeout: flagRequestTimeout,
+	}
+
+	// размер тела ограничивается и до распаковки, и после неё
+	webhook = authMiddleware(auth, webhook)
+	webhook = bodyLimitMiddleware(server.maxBodyBytes, webhook)
+	webhook = gzipMiddleware(webhook)
+	webhook = bodyLimitMiddleware(server.maxBodyBytes, webhook)
+	webhook = fallbackMiddleware(flagResponseBudget, webhook)
+	webhook = requestTimeoutMiddleware(server.requestTimeout, webhook)
+	webhook = tracingMiddleware(webhook)
+	webhook = metricsMiddleware(webhook)
+	webhook = accessLogMiddleware(webhook)
+	// корень оставлен для навыков, настроенных до переноса вебхука
+	if flagWebhookPath != "/" {
+		mux.Handle(flagWebhookPath, webhook)
+	}
+	mux.Handle("/", webhook)
+
+	return serve(ctx, newServer(flagRunAddr, mux, server))
+}
+
+// shutdownTimeout ограничивает завершение запросов и отправку спанов при остановке.
+const shutdownTimeout = 10 * time.Second
+
+// serve обслуживает запросы, пока не отменён ctx, а затем дожидается
+// завершения начатых запросов.
+func serve(ctx context.Context, srv *http.Server) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
 
-	appInstance := newApp(pg.NewStore(conn))
-	return http.ListenAndServe(flagRunAddr, logger.RequestLogger(gzipMiddleware(appInstance.webhook)))
+	logger.Log.Info("shutting down server")
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
 }
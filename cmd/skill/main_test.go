@@ -1,15 +1,22 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/VladimirAzanza/alisa_skill/internal/models"
+	"github.com/VladimirAzanza/alisa_skill/internal/moderation"
+	"github.com/VladimirAzanza/alisa_skill/internal/oauth"
 	"github.com/VladimirAzanza/alisa_skill/internal/store"
+	"github.com/VladimirAzanza/alisa_skill/internal/username"
 	"github.com/VladimirAzanza/alisa_skill/mocks"
 	"github.com/go-resty/resty/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
 
@@ -17,19 +24,23 @@ func TestWebhook(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	s := mocks.NewMockStore(ctrl)
 
-	messages := []store.Message{
+	summaries := []store.SenderSummary{
 		{
-			Sender:  "411419e5-f5be-4cdb-83aa-2ca2b6648353",
-			Time:    time.Now(),
-			Payload: "Hello!",
+			SenderID: "411419e5-f5be-4cdb-83aa-2ca2b6648353",
+			Sender:   "Иван",
+			Count:    1,
+			Latest:   time.Now(),
 		},
 	}
 
 	s.EXPECT().
-		ListMessages(gomock.Any(), gomock.Any()).
-		Return(messages, nil)
+		SummarizeUnread(gomock.Any(), gomock.Any()).
+		Return(summaries, nil)
+	s.EXPECT().
+		ListDueReminders(gomock.Any(), gomock.Any()).
+		Return(nil, nil)
 
-	appInstance := newApp(s)
+	appInstance := newApp(s, appConfig{usernames: username.DefaultPolicy()})
 
 	handler := http.HandlerFunc(appInstance.webhook)
 	srv := httptest.NewServer(handler)
@@ -76,9 +87,9 @@ func TestWebhook(t *testing.T) {
 		{
 			name:         "method_post_success",
 			method:       http.MethodPost,
-			body:         `{"request": {"type": "SimpleUtterance", "command": "sudo do something"}, "session": {"new": true}, "version": "1.0"}`,
+			body:         `{"request": {"type": "SimpleUtterance", "command": "sudo do something"}, "session": {"new": true, "application": {"application_id": "app"}}, "version": "1.0"}`,
 			expectedCode: http.StatusOK,
-			expectedBody: `Точное время .* часов, .* минут. Для вас 1 новых сообщений.`,
+			expectedBody: `Точное время .* часов, .* минут. Для вас 1 новое сообщение: 1 от Ивана.`,
 		},
 	}
 
@@ -103,3 +114,381 @@ func TestWebhook(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveRecepient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewMockStore(ctrl)
+
+	s.EXPECT().
+		FindContact(gomock.Any(), "user", "мама").
+		Return("mom-id", nil)
+
+	s.EXPECT().
+		FindContact(gomock.Any(), "user", "ivan").
+		Return("", store.ErrNotFound)
+	s.EXPECT().
+		FindRecepient(gomock.Any(), "Ivan").
+		Return("ivan-id", nil)
+
+	s.EXPECT().
+		FindContact(gomock.Any(), "user", gomock.Any()).
+		Return("", store.ErrNotFound).
+		Times(2)
+	s.EXPECT().
+		FindRecepient(gomock.Any(), "Маше").
+		Return("", store.ErrNotFound)
+	s.EXPECT().
+		FindRecepient(gomock.Any(), "Ивану").
+		Return("", store.ErrNotFound)
+	s.EXPECT().
+		ListContacts(gomock.Any(), "user").
+		Return(map[string]string{"маша": "masha-id"}, nil).
+		Times(2)
+	s.EXPECT().
+		FindUsernames(gomock.Any(), gomock.Any()).
+		Return([]string{"Иван", "Ивана", "Мария"}, nil).
+		Times(2)
+
+	appInstance := newApp(s, appConfig{usernames: username.DefaultPolicy()})
+
+	recepientID, err := appInstance.resolveRecepient(context.Background(), "user", "Мама", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "mom-id", recepientID)
+
+	recepientID, err = appInstance.resolveRecepient(context.Background(), "user", "Ivan", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "ivan-id", recepientID)
+
+	recepientID, err = appInstance.resolveRecepient(context.Background(), "user", "Маше", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "masha-id", recepientID)
+
+	_, err = appInstance.resolveRecepient(context.Background(), "user", "Ивану", nil)
+	text, ok := recepientErrorText(err, "Ивану")
+	assert.True(t, ok)
+	assert.Equal(t, "Вы имели в виду Иван или Ивана?", text)
+}
+
+func TestWebhookDeleteAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewMockStore(ctrl)
+
+	s.EXPECT().
+		DeleteUser(gomock.Any(), "user").
+		Return(nil)
+
+	appInstance := newApp(s, appConfig{usernames: username.DefaultPolicy()})
+
+	srv := httptest.NewServer(http.HandlerFunc(appInstance.webhook))
+	defer srv.Close()
+
+	var resp models.Response
+	_, err := resty.New().R().
+		SetBody(`{"request": {"type": "SimpleUtterance", "command": "Удали мой аккаунт"}, "session": {"user": {"user_id": "user"}}, "version": "1.0"}`).
+		SetResult(&resp).
+		Post(srv.URL)
+	assert.NoError(t, err)
+	assert.True(t, resp.SessionState.ConfirmDeletion)
+
+	resp = models.Response{}
+	_, err = resty.New().R().
+		SetBody(`{"request": {"type": "SimpleUtterance", "command": "да"}, "session": {"user": {"user_id": "user"}}, "state": {"session": {"confirm_deletion": true}}, "version": "1.0"}`).
+		SetResult(&resp).
+		Post(srv.URL)
+	assert.NoError(t, err)
+	assert.False(t, resp.SessionState.ConfirmDeletion)
+	assert.Contains(t, resp.Response.Text, "Ваш аккаунт удалён")
+}
+
+func TestWebhookCommand(t *testing.T) {
+	appInstance := newApp(mocks.NewMockStore(gomock.NewController(t)), appConfig{usernames: username.DefaultPolicy()})
+
+	testCases := []struct {
+		body     string
+		expected string
+	}{
+		{body: `{"request": {"type": "SimpleUtterance", "command": "Удали мой аккаунт"}, "session": {"user": {"user_id": "user"}}, "version": "1.0"}`, expected: "delete_account"},
+		{body: `{"request": {"type": "SimpleUtterance", "command": "нет"}, "session": {"user": {"user_id": "user"}}, "state": {"session": {"confirm_deletion": true}}, "version": "1.0"}`, expected: "delete_account"},
+		{body: `{"request": {"type": "SimpleUtterance", "command": "Напомни"}, "session": {"user": {"user_id": "user"}}, "version": "1.0"}`, expected: "remind"},
+		{body: `{"request": {"type": "SimpleUtterance", "command": "Отправь Маше привет"}, "session": {"application": {"application_id": "app"}}, "version": "1.0"}`, expected: "anonymous"},
+	}
+
+	for _, tc := range testCases {
+		ctx, info := withRequestInfo(context.Background())
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body)).WithContext(ctx)
+		appInstance.webhook(httptest.NewRecorder(), r)
+		assert.Equal(t, tc.expected, info.Command(), tc.body)
+	}
+}
+
+func TestWebhookReadAfterTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewMockStore(ctrl)
+
+	// ответ уже не дойдёт до пользователя, поэтому MarkRead не вызывается
+	s.EXPECT().
+		ListMessages(gomock.Any(), "user").
+		Return([]store.Message{{ID: 7}}, nil)
+	s.EXPECT().
+		GetMessage(gomock.Any(), int64(7)).
+		Return(&store.Message{ID: 7, Sender: "ivan", Payload: "привет"}, nil)
+
+	appInstance := newApp(s, appConfig{usernames: username.DefaultPolicy()})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	body := `{"request": {"type": "SimpleUtterance", "command": "Прочитай"}, "session": {"user": {"user_id": "user"}}, "version": "1.0"}`
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)).WithContext(ctx)
+	appInstance.webhook(httptest.NewRecorder(), r)
+}
+
+func TestWebhookReadReceipt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewMockStore(ctrl)
+
+	readAt := time.Date(2024, time.March, 10, 14, 5, 0, 0, time.UTC)
+
+	s.EXPECT().
+		FindContact(gomock.Any(), "user", "иван").
+		Return("", store.ErrNotFound).
+		AnyTimes()
+	s.EXPECT().
+		FindRecepient(gomock.Any(), "Иван").
+		Return("ivan-id", nil).
+		AnyTimes()
+	gomock.InOrder(
+		s.EXPECT().
+			GetReceipt(gomock.Any(), "user", "ivan-id").
+			Return(&store.Message{Recepient: "Иван", ReadAt: readAt}, nil),
+		s.EXPECT().
+			GetReceipt(gomock.Any(), "user", "ivan-id").
+			Return(nil, store.ErrReceiptsDisabled),
+	)
+
+	appInstance := newApp(s, appConfig{usernames: username.DefaultPolicy()})
+
+	srv := httptest.NewServer(http.HandlerFunc(appInstance.webhook))
+	defer srv.Close()
+
+	expected := []string{
+		"Иван прочитал ваше последнее сообщение 10.03 в 17:05.",
+		"Иван не сообщает о прочтении сообщений.",
+	}
+	for _, text := range expected {
+		var resp models.Response
+		_, err := resty.New().R().
+			SetBody(`{"request": {"type": "SimpleUtterance", "command": "Прочитал ли Иван моё сообщение?"}, "session": {"user": {"user_id": "user"}}, "timezone": "Europe/Moscow", "version": "1.0"}`).
+			SetResult(&resp).
+			Post(srv.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, text, resp.Response.Text)
+	}
+}
+
+func TestWebhookModeration(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewMockStore(ctrl)
+
+	s.EXPECT().
+		ListGroups(gomock.Any(), "user").
+		Return([]string{"семья"}, nil)
+	s.EXPECT().
+		FindContact(gomock.Any(), "user", "маше").
+		Return("", store.ErrNotFound)
+	s.EXPECT().
+		FindRecepient(gomock.Any(), "Маше").
+		Return("masha-id", nil)
+
+	// без фоновой записи, чтобы прочитать сообщение из очереди
+	appInstance := &app{
+		store: s,
+		config: appConfig{
+			usernames: username.DefaultPolicy(),
+			moderator: moderation.Dictionary{Words: []string{"мудак"}},
+		},
+		spam:    newSpamGuard(limitsConfig{}),
+		msgChan: make(chan queuedMessage, 1),
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(appInstance.webhook))
+	defer srv.Close()
+
+	var resp models.Response
+	_, err := resty.New().R().
+		SetBody(`{"request": {"type": "SimpleUtterance", "command": "Отправь Маше ты мудак", "markup": {"dangerous_context": true}}, "session": {"user": {"user_id": "user"}}, "version": "1.0"}`).
+		SetResult(&resp).
+		Post(srv.URL)
+	assert.NoError(t, err)
+	assert.Contains(t, resp.Response.Text, "Я не могу передать такое сообщение")
+
+	resp = models.Response{}
+	_, err = resty.New().R().
+		SetBody(`{"request": {"type": "SimpleUtterance", "command": "Отправь Маше ты мудак"}, "session": {"user": {"user_id": "user"}}, "version": "1.0"}`).
+		SetResult(&resp).
+		Post(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "Сообщение успешно отправлено", resp.Response.Text)
+
+	msg := <-appInstance.msgChan
+	assert.Equal(t, "masha-id", msg.Recepient)
+	assert.Equal(t, "ты м****", msg.Payload)
+}
+
+func TestWebhookAnonymous(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewMockStore(ctrl)
+
+	s.EXPECT().
+		MigrateUser(gomock.Any(), "app:device", "user").
+		Return(nil)
+	s.EXPECT().
+		GetUser(gomock.Any(), "user").
+		Return(&store.User{ID: "user", Username: "Иван"}, nil)
+
+	appInstance := newApp(s, appConfig{usernames: username.DefaultPolicy()})
+
+	srv := httptest.NewServer(http.HandlerFunc(appInstance.webhook))
+	defer srv.Close()
+
+	var resp models.Response
+	_, err := resty.New().R().
+		SetBody(`{"request": {"type": "SimpleUtterance", "command": "Отправь Маше привет"}, "session": {"application": {"application_id": "device"}}, "version": "1.0"}`).
+		SetResult(&resp).
+		Post(srv.URL)
+	assert.NoError(t, err)
+	assert.Contains(t, resp.Response.Text, "войдите в аккаунт Яндекса")
+
+	resp = models.Response{}
+	_, err = resty.New().R().
+		SetBody(`{"request": {"type": "SimpleUtterance", "command": "Как меня зовут"}, "session": {"new": true, "user": {"user_id": "user"}, "application": {"application_id": "device"}}, "version": "1.0"}`).
+		SetResult(&resp).
+		Post(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "Ваш аккаунт в навыке теперь привязан к аккаунту Яндекса. Вы зарегистрированы под именем Иван", resp.Response.Text)
+
+	r, err := resty.New().R().
+		SetBody(`{"request": {"type": "SimpleUtterance", "command": "Как меня зовут"}, "session": {}, "version": "1.0"}`).
+		Post(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, r.StatusCode())
+}
+
+func TestWebhookAccountLinking(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewMockStore(ctrl)
+
+	s.EXPECT().
+		FindTokenAccount(gomock.Any(), oauth.HashToken("token")).
+		Return("ivanov", nil)
+	s.EXPECT().
+		MigrateUser(gomock.Any(), "user", "account:ivanov").
+		Return(nil)
+
+	appInstance := newApp(s, appConfig{usernames: username.DefaultPolicy(), accountLinking: true})
+
+	srv := httptest.NewServer(http.HandlerFunc(appInstance.webhook))
+	defer srv.Close()
+
+	var resp models.Response
+	_, err := resty.New().R().
+		SetBody(`{"request": {"type": "SimpleUtterance", "command": "Привяжи аккаунт"}, "session": {"user": {"user_id": "user"}}, "version": "1.0"}`).
+		SetResult(&resp).
+		Post(srv.URL)
+	assert.NoError(t, err)
+	assert.NotNil(t, resp.StartAccountLinking)
+
+	resp = models.Response{}
+	_, err = resty.New().R().
+		SetHeader("Authorization", "Bearer token").
+		SetBody(`{"account_linking_complete_event": {}, "session": {"user": {"user_id": "user"}}, "version": "1.0"}`).
+		SetResult(&resp).
+		Post(srv.URL)
+	assert.NoError(t, err)
+	assert.Nil(t, resp.StartAccountLinking)
+	assert.Equal(t, "Аккаунт привязан. Теперь навык знает вас по корпоративной учётной записи.", resp.Response.Text)
+}
+
+func TestWebhookSaveContact(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewMockStore(ctrl)
+
+	s.EXPECT().
+		FindContact(gomock.Any(), "user", "петя").
+		Return("", store.ErrNotFound)
+	s.EXPECT().
+		FindRecepient(gomock.Any(), "Петя").
+		Return("", store.ErrNotFound)
+	s.EXPECT().
+		ListContacts(gomock.Any(), "user").
+		Return(nil, nil)
+	s.EXPECT().
+		FindUsernames(gomock.Any(), gomock.Any()).
+		Return(nil, nil)
+
+	appInstance := newApp(s, appConfig{usernames: username.DefaultPolicy()})
+
+	srv := httptest.NewServer(http.HandlerFunc(appInstance.webhook))
+	defer srv.Close()
+
+	testCases := []struct {
+		command  string
+		expected string
+	}{
+		{command: "Сохрани контакт Петя", expected: "Скажите, например: «Сохрани контакт ivan как брат»."},
+		{command: "Сохрани контакт Петя как брат", expected: "Не нашла пользователя с именем Петя."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			var resp models.Response
+			r, err := resty.New().R().
+				SetBody(`{"request": {"type": "SimpleUtterance", "command": "` + tc.command + `"}, "session": {"user": {"user_id": "user"}}, "version": "1.0"}`).
+				SetResult(&resp).
+				Post(srv.URL)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, r.StatusCode())
+			assert.Equal(t, tc.expected, resp.Response.Text)
+		})
+	}
+}
+
+func TestWebhookSendToGroup(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := mocks.NewMockStore(ctrl)
+
+	s.EXPECT().
+		ListGroups(gomock.Any(), "user").
+		Return([]string{"работа", "семья"}, nil)
+	s.EXPECT().
+		ListGroupMembers(gomock.Any(), "user", "семья").
+		Return([]string{"user", "masha-id", "petya-id"}, nil)
+
+	// без фоновой записи, чтобы прочитать сообщения из очереди
+	appInstance := &app{
+		store:   s,
+		config:  appConfig{usernames: username.DefaultPolicy()},
+		spam:    newSpamGuard(limitsConfig{}),
+		msgChan: make(chan queuedMessage, 4),
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(appInstance.webhook))
+	defer srv.Close()
+
+	var resp models.Response
+	_, err := resty.New().R().
+		SetBody(`{"request": {"type": "SimpleUtterance", "command": "Отправь семье ужин в семь"}, "session": {"user": {"user_id": "user"}}, "version": "1.0"}`).
+		SetResult(&resp).
+		Post(srv.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "Сообщение успешно отправлено группе семье", resp.Response.Text)
+
+	// отправитель сам себе сообщение не получает
+	require.Len(t, appInstance.msgChan, 2)
+	for _, recepient := range []string{"masha-id", "petya-id"} {
+		msg := <-appInstance.msgChan
+		assert.Equal(t, recepient, msg.Recepient)
+		assert.Equal(t, "user", msg.Sender)
+		assert.Equal(t, "семья", msg.Group)
+		assert.Equal(t, "ужин в семь", msg.Payload)
+	}
+}
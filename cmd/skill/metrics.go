@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/metrics"
+	"github.com/VladimirAzanza/alisa_skill/internal/store"
+)
+
+var (
+	requestsTotal = metrics.Default.NewCounterVec("skill_requests_total",
+		"Webhook requests by resolved command and HTTP status.", "command", "status")
+	requestDuration = metrics.Default.NewHistogramVec("skill_request_duration_seconds",
+		"Webhook request latency by resolved command and HTTP status.", metrics.DefaultBuckets, "command", "status")
+	flushBatchSize = metrics.Default.NewHistogramVec("skill_flush_batch_size",
+		"Messages saved by one flush.", []float64{1, 5, 10, 50, 100, 500, 1000})
+	flushDuration = metrics.Default.NewHistogramVec("skill_flush_duration_seconds",
+		"Latency of saving a batch of messages.", metrics.DefaultBuckets)
+	flushFailures = metrics.Default.NewCounterVec("skill_flush_failures_total",
+		"Batches of messages that could not be saved and were kept for a retry.")
+	purgedMessages = metrics.Default.NewCounterVec("skill_purged_messages_total",
+		"Messages removed or archived by the retention purge.")
+	purgeFailures = metrics.Default.NewCounterVec("skill_purge_failures_total",
+		"Retention purge batches that failed.")
+	storeDuration = metrics.Default.NewHistogramVec("skill_store_duration_seconds",
+		"Store call latency by method.", metrics.DefaultBuckets, "method")
+	storeErrors = metrics.Default.NewCounterVec("skill_store_errors_total",
+		"Store calls that failed, not counting missing rows and conflicts.", "method")
+	registrationConflicts = metrics.Default.NewCounterVec("skill_registration_conflicts_total",
+		"Registrations and renames rejected because the username is taken.", "command")
+)
+
+// unknownCommand помечает запросы, отклонённые до разбора команды.
+const unknownCommand = "unknown"
+
+// registerQueueMetrics отдаёт суммарную длину очередей сообщений навыков.
+func registerQueueMetrics(apps []*app) {
+	metrics.Default.NewGaugeFunc("skill_queue_depth",
+		"Messages waiting in memory to be saved.", func() float64 {
+			var depth int
+			for _, a := range apps {
+				depth += len(a.msgChan)
+			}
+			return float64(depth)
+		})
+}
+
+// storeMetrics измеряет обращения к хранилищу; отсутствие строки и конфликт
+// входят в обычную работу навыка и ошибками не считаются.
+func storeMetrics(ctx context.Context, method string) (context.Context, func(err error)) {
+	start := time.Now()
+	return ctx, func(err error) {
+		storeDuration.With(method).Observe(time.Since(start).Seconds())
+		if err != nil && !errors.Is(err, store.ErrNotFound) && !errors.Is(err, store.ErrConflict) {
+			storeErrors.With(method).Inc()
+		}
+	}
+}
+
+// requestInfo собирает сведения о запросе, которые становятся известны
+// только внутри обработчика. Обработчик может дописывать их уже после того,
+// как клиенту ушла заглушка, поэтому доступ защищён мьютексом.
+type requestInfo struct {
+	mu        sync.Mutex
+	command   string
+	sessionID string
+	messageID int64
+}
+
+type requestInfoKey struct{}
+
+// withRequestInfo добавляет в контекст пустой requestInfo,
+// если его туда ещё не положило внешнее middleware.
+func withRequestInfo(ctx context.Context) (context.Context, *requestInfo) {
+	if info := requestInfoFrom(ctx); info != nil {
+		return ctx, info
+	}
+	info := &requestInfo{}
+	return context.WithValue(ctx, requestInfoKey{}, info), info
+}
+
+// requestInfoFrom возвращает requestInfo запроса или nil.
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// setCommand запоминает команду, которую распознал обработчик.
+func setCommand(ctx context.Context, command string) {
+	if info := requestInfoFrom(ctx); info != nil {
+		info.mu.Lock()
+		info.command = command
+		info.mu.Unlock()
+	}
+}
+
+// setSession запоминает сессию Алисы и номер сообщения в ней.
+func setSession(ctx context.Context, sessionID string, messageID int64) {
+	if info := requestInfoFrom(ctx); info != nil {
+		info.mu.Lock()
+		info.sessionID, info.messageID = sessionID, messageID
+		info.mu.Unlock()
+	}
+}
+
+// Session возвращает сессию Алисы и номер сообщения в ней.
+func (i *requestInfo) Session() (string, int64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.sessionID, i.messageID
+}
+
+func (i *requestInfo) Command() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.command == "" {
+		return unknownCommand
+	}
+	return i.command
+}
+
+// statusRecorder запоминает код ответа и число отправленных байт.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Status возвращает код ответа; если обработчик ничего не записал, это 200.
+func (r *statusRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// metricsMiddleware считает запросы и их длительность по командам и кодам ответа.
+func metricsMiddleware(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx, info := withRequestInfo(r.Context())
+		rec := &statusRecorder{ResponseWriter: w}
+
+		h(rec, r.WithContext(ctx))
+
+		command, status := info.Command(), strconv.Itoa(rec.Status())
+		requestsTotal.With(command, status).Inc()
+		requestDuration.With(command, status).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	handler := metricsMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		setCommand(r.Context(), "metrics_test")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("{}"))
+	})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+
+	var out strings.Builder
+	_, err := metrics.Default.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `skill_requests_total{command="metrics_test",status="418"} 1`)
+	assert.Contains(t, out.String(), `skill_request_duration_seconds_count{command="metrics_test",status="418"} 1`)
+}
+
+func TestMetricsMiddlewareUnknownCommand(t *testing.T) {
+	handler := metricsMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var out strings.Builder
+	_, err := metrics.Default.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `skill_requests_total{command="unknown",status="405"}`)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/models"
+	"github.com/VladimirAzanza/alisa_skill/internal/store"
+)
+
+const (
+	// readAllBatch ограничивает число сообщений, загружаемых для одной страницы.
+	readAllBatch = 20
+	// readAllPause задаёт паузу между сообщениями в разметке TTS.
+	readAllPause = " sil <[700]> "
+	// readAllContinue завершает страницу, после которой есть ещё сообщения.
+	readAllContinue = "Продолжить?"
+)
+
+// readAllPage описывает одну страницу режима «Прочитай все».
+type readAllPage struct {
+	text string
+	tts  string
+	// spoken содержит идентификаторы сообщений, вошедших в страницу.
+	spoken []int64
+	// more выставляется, если после страницы остались непрочитанные сообщения.
+	more bool
+}
+
+// buildReadAllPage собирает из сообщений страницу, которая укладывается
+// в ограничения Алисы на длину текста и TTS. В страницу всегда попадает
+// хотя бы одно сообщение: слишком длинное обрезается.
+func buildReadAllPage(messages []store.Message) readAllPage {
+	var page readAllPage
+	var texts, ttss []string
+	textLen, ttsLen := 0, 0
+
+	// оставим место для вопроса о продолжении
+	reserve := len([]rune(readAllContinue)) + 1
+
+	for i, message := range messages {
+		item := fmt.Sprintf("Сообщение от %s: %s", senderGenitive(message.Sender), message.Payload)
+		if message.Group != "" {
+			item = fmt.Sprintf("Сообщение от %s в группе %s: %s", senderGenitive(message.Sender), message.Group, message.Payload)
+		}
+		itemLen := len([]rune(item)) + 1
+		pauseLen := len([]rune(readAllPause))
+
+		if i == 0 {
+			// первое сообщение обрезается, если не помещается целиком
+			item = truncate(item, min(models.MaxTextLength, models.MaxTTSLength-pauseLen)-reserve)
+			itemLen = len([]rune(item)) + 1
+		} else if textLen+itemLen+reserve > models.MaxTextLength || ttsLen+itemLen+pauseLen+reserve > models.MaxTTSLength {
+			page.more = true
+			break
+		}
+
+		texts = append(texts, item)
+		ttss = append(ttss, item)
+		textLen += itemLen
+		ttsLen += itemLen + pauseLen
+		page.spoken = append(page.spoken, message.ID)
+	}
+
+	if len(page.spoken) == len(messages) && len(messages) == readAllBatch {
+		// загружена полная пачка: возможно, непрочитанные остались в хранилище
+		page.more = true
+	}
+
+	page.text = strings.Join(texts, "\n")
+	page.tts = strings.Join(ttss, readAllPause)
+	if page.more {
+		page.text += "\n" + readAllContinue
+		page.tts += readAllPause + readAllContinue
+	}
+	return page
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/models"
+	"github.com/VladimirAzanza/alisa_skill/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildReadAllPage(t *testing.T) {
+	t.Run("fits", func(t *testing.T) {
+		page := buildReadAllPage([]store.Message{
+			{ID: 1, Sender: "Иван", Payload: "Привет!"},
+			{ID: 2, Sender: "Маша", Payload: "Купи хлеб", Group: "семья"},
+		})
+
+		assert.Equal(t, []int64{1, 2}, page.spoken)
+		assert.False(t, page.more)
+		assert.Equal(t, "Сообщение от Ивана: Привет!\nСообщение от Маши в группе семья: Купи хлеб", page.text)
+		assert.Equal(t, "Сообщение от Ивана: Привет! sil <[700]> Сообщение от Маши в группе семья: Купи хлеб", page.tts)
+	})
+
+	t.Run("split", func(t *testing.T) {
+		var messages []store.Message
+		for i := range 5 {
+			messages = append(messages, store.Message{ID: int64(i + 1), Sender: "Иван", Payload: strings.Repeat("а", 300)})
+		}
+
+		page := buildReadAllPage(messages)
+
+		assert.Equal(t, []int64{1, 2, 3}, page.spoken)
+		assert.True(t, page.more)
+		assert.True(t, strings.HasSuffix(page.text, readAllContinue))
+		assert.LessOrEqual(t, len([]rune(page.text)), models.MaxTextLength)
+		assert.LessOrEqual(t, len([]rune(page.tts)), models.MaxTTSLength)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		page := buildReadAllPage([]store.Message{
+			{ID: 1, Sender: "Иван", Payload: strings.Repeat("а", 2000)},
+			{ID: 2, Sender: "Иван", Payload: "Привет!"},
+		})
+
+		assert.Equal(t, []int64{1}, page.spoken)
+		assert.True(t, page.more)
+		assert.LessOrEqual(t, len([]rune(page.text)), models.MaxTextLength)
+		assert.LessOrEqual(t, len([]rune(page.tts)), models.MaxTTSLength)
+	})
+}
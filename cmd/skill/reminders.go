@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/models"
+	"github.com/VladimirAzanza/alisa_skill/internal/store"
+)
+
+// weekdays сопоставляет названия дней недели в винительном падеже с time.Weekday.
+var weekdays = map[string]time.Weekday{
+	"понедельник": time.Monday,
+	"вторник":     time.Tuesday,
+	"среду":       time.Wednesday,
+	"четверг":     time.Thursday,
+	"пятницу":     time.Friday,
+	"субботу":     time.Saturday,
+	"воскресенье": time.Sunday,
+}
+
+// everyWeekday содержит периодичность еженедельных напоминаний для озвучивания.
+var everyWeekday = map[time.Weekday]string{
+	time.Monday:    "каждый понедельник",
+	time.Tuesday:   "каждый вторник",
+	time.Wednesday: "каждую среду",
+	time.Thursday:  "каждый четверг",
+	time.Friday:    "каждую пятницу",
+	time.Saturday:  "каждую субботу",
+	time.Sunday:    "каждое воскресенье",
+}
+
+// reminder описывает напоминание, разобранное из команды «Напомни мне ...».
+type reminder struct {
+	text       string
+	at         time.Time
+	recurrence string
+}
+
+// parseReminderCommand разбирает команду вида
+// "Напомни мне в 18:00 купить хлеб" или "Напомни мне каждый понедельник в 9 вынести мусор".
+// Время берётся из сущности YANDEX.DATETIME, периодичность — из слов «каждый день»
+// и «каждый <день недели>». Если время не названо, возвращается false.
+func parseReminderCommand(utterance models.SimpleUtterance, now time.Time) (reminder, bool) {
+	tokens := utterance.Nlu.Tokens
+	if len(tokens) == 0 {
+		tokens = strings.Fields(strings.ToLower(utterance.Command))
+	}
+
+	var r reminder
+	at, entity, hasTime := parseDateTime(utterance.Nlu, now)
+	weekday, hasWeekday := time.Weekday(0), false
+
+	var words []string
+	for i := 0; i < len(tokens); i++ {
+		token := strings.Trim(tokens[i], ":,.")
+		switch {
+		case hasTime && i >= entity.Start && i < entity.End:
+			continue
+		case i == 0 && token == "напомни", i == 1 && token == "мне":
+			continue
+		case strings.HasPrefix(token, "кажд") && i+1 < len(tokens):
+			next := strings.Trim(tokens[i+1], ":,.")
+			if next == "день" {
+				r.recurrence = store.RecurrenceDaily
+				i++
+				continue
+			}
+			if wd, ok := weekdays[next]; ok {
+				r.recurrence = store.RecurrenceWeekly
+				weekday, hasWeekday = wd, true
+				i++
+				continue
+			}
+		}
+		words = append(words, tokens[i])
+	}
+	r.text = strings.Join(words, " ")
+
+	switch {
+	case hasWeekday:
+		hour, minute := defaultDeliveryHour, 0
+		if hasTime {
+			hour, minute = at.Hour(), at.Minute()
+		}
+		r.at = nextWeekday(now, weekday, hour, minute)
+	case hasTime:
+		r.at = at
+	case r.recurrence == store.RecurrenceDaily:
+		hour := defaultDeliveryHour
+		r.at = resolveDateTime(models.DateTime{Hour: &hour}, now)
+	default:
+		return reminder{}, false
+	}
+
+	return r, r.text != ""
+}
+
+// nextWeekday возвращает ближайший после now момент в день недели weekday
+// в заданное время.
+func nextWeekday(now time.Time, weekday time.Weekday, hour, minute int) time.Time {
+	days := (int(weekday) - int(now.Weekday()) + 7) % 7
+	t := time.Date(now.Year(), now.Month(), now.Day()+days, hour, minute, 0, 0, now.Location())
+	if !t.After(now) {
+		t = t.AddDate(0, 0, 7)
+	}
+	return t
+}
+
+// nextOccurrence возвращает следующий после now срок периодического напоминания
+// или нулевое время для разового. Пропущенные, пока пользователь не заходил, сроки
+// не накапливаются.
+func nextOccurrence(r store.Message, now time.Time) time.Time {
+	var step func(time.Time) time.Time
+	switch r.Recurrence {
+	case store.RecurrenceDaily:
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case store.RecurrenceWeekly:
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	default:
+		return time.Time{}
+	}
+
+	next := step(r.DeliverAt.In(now.Location()))
+	for !next.After(now) {
+		next = step(next)
+	}
+	return next
+}
+
+// describeRecurrence возвращает периодичность напоминания для озвучивания
+// в часовом поясе loc.
+func describeRecurrence(r store.Message, loc *time.Location) string {
+	switch r.Recurrence {
+	case store.RecurrenceDaily:
+		return "каждый день"
+	case store.RecurrenceWeekly:
+		return everyWeekday[r.DeliverAt.In(loc).Weekday()]
+	}
+	return ""
+}
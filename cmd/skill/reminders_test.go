@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/models"
+	"github.com/VladimirAzanza/alisa_skill/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReminderCommand(t *testing.T) {
+	// воскресенье, 10 марта 2024
+	now := time.Date(2024, time.March, 10, 14, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name       string
+		utterance  models.SimpleUtterance
+		ok         bool
+		text       string
+		at         time.Time
+		recurrence string
+	}{
+		{
+			name: "once",
+			utterance: models.SimpleUtterance{
+				Command: "Напомни мне в 18:00 купить хлеб",
+				Nlu: models.Nlu{
+					Tokens: []string{"напомни", "мне", "в", "18", "00", "купить", "хлеб"},
+					Entities: []models.Entity{{
+						Type:   models.EntityDateTime,
+						Tokens: models.EntityTokens{Start: 2, End: 5},
+						Value:  json.RawMessage(`{"hour": 18, "minute": 0}`),
+					}},
+				},
+			},
+			ok:   true,
+			text: "купить хлеб",
+			at:   time.Date(2024, time.March, 10, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "weekly",
+			utterance:  models.SimpleUtterance{Command: "Напомни мне каждый понедельник вынести мусор"},
+			ok:         true,
+			text:       "вынести мусор",
+			at:         time.Date(2024, time.March, 11, 9, 0, 0, 0, time.UTC),
+			recurrence: store.RecurrenceWeekly,
+		},
+		{
+			name:      "without_time",
+			utterance: models.SimpleUtterance{Command: "Напомни мне купить хлеб"},
+			ok:        false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, ok := parseReminderCommand(tc.utterance, now)
+			assert.Equal(t, tc.ok, ok)
+			if !tc.ok {
+				return
+			}
+			assert.Equal(t, tc.text, r.text)
+			assert.Equal(t, tc.at, r.at)
+			assert.Equal(t, tc.recurrence, r.recurrence)
+		})
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	now := time.Date(2024, time.March, 20, 12, 0, 0, 0, time.UTC)
+	due := time.Date(2024, time.March, 4, 9, 0, 0, 0, time.UTC)
+
+	assert.True(t, nextOccurrence(store.Message{DeliverAt: due}, now).IsZero())
+	assert.Equal(t,
+		time.Date(2024, time.March, 25, 9, 0, 0, 0, time.UTC),
+		nextOccurrence(store.Message{DeliverAt: due, Recurrence: store.RecurrenceWeekly}, now),
+	)
+	assert.Equal(t,
+		time.Date(2024, time.March, 21, 9, 0, 0, 0, time.UTC),
+		nextOccurrence(store.Message{DeliverAt: due, Recurrence: store.RecurrenceDaily}, now),
+	)
+}
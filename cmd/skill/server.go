@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// serverConfig задаёт ограничения HTTP-сервера.
+type serverConfig struct {
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+	// maxHeaderBytes ограничивает размер заголовков запроса.
+	maxHeaderBytes int
+	// maxBodyBytes ограничивает тело запроса как в сжатом, так и в распакованном виде.
+	maxBodyBytes int64
+	// requestTimeout ограничивает обработку одного запроса: Алиса ждёт ответа
+	// около трёх секунд, дольше работать бесполезно.
+	requestTimeout time.Duration
+}
+
+// newServer создаёт HTTP-сервер с тайм-аутами и ограничениями из config.
+func newServer(addr string, handler http.Handler, config serverConfig) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: config.readTimeout,
+		ReadTimeout:       config.readTimeout,
+		WriteTimeout:      config.writeTimeout,
+		IdleTimeout:       config.idleTimeout,
+		MaxHeaderBytes:    config.maxHeaderBytes,
+	}
+}
+
+// bodyLimitMiddleware ограничивает размер тела запроса. Снаружи gzipMiddleware
+// он ограничивает сжатое тело, внутри — распакованное, что защищает от gzip-бомб.
+func bodyLimitMiddleware(limit int64, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if limit > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		h(w, r)
+	}
+}
+
+// requestTimeoutMiddleware отменяет контекст запроса через timeout,
+// чтобы обращения к хранилищу не продолжались после того, как Алиса перестала ждать.
+func requestTimeoutMiddleware(timeout time.Duration, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if timeout <= 0 {
+			h(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		h(w, r.WithContext(ctx))
+	}
+}
+
+// bodyErrorStatus возвращает код ответа на ошибку чтения тела запроса.
+func bodyErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyLimit(t *testing.T) {
+	const limit = 1 << 10
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	// сжатое тело ограничено свободнее, чтобы проверить именно лимит после распаковки
+	handler := bodyLimitMiddleware(4*limit, gzipMiddleware(bodyLimitMiddleware(limit, authMiddleware(authConfig{}, ok))))
+
+	// мегабайт нулей сжимается в пару килобайт, но распакованным не помещается в лимит
+	var bomb bytes.Buffer
+	zw := gzip.NewWriter(&bomb)
+	_, err := zw.Write(make([]byte, 1<<20))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.Less(t, bomb.Len(), 4*limit)
+
+	testCases := []struct {
+		name         string
+		body         []byte
+		gzip         bool
+		expectedCode int
+	}{
+		{name: "small", body: []byte(`{}`), expectedCode: http.StatusOK},
+		{name: "large", body: []byte(strings.Repeat("a", 2*limit)), expectedCode: http.StatusRequestEntityTooLarge},
+		{name: "gzip_bomb", body: bomb.Bytes(), gzip: true, expectedCode: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tc.body))
+			if tc.gzip {
+				r.Header.Set("Content-Encoding", "gzip")
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	var deadline time.Time
+	handler := requestTimeoutMiddleware(3*time.Second, func(w http.ResponseWriter, r *http.Request) {
+		deadline, _ = r.Context().Deadline()
+	})
+
+	start := time.Now()
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+
+	assert.WithinDuration(t, start.Add(3*time.Second), deadline, time.Second)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/logger"
+	"github.com/VladimirAzanza/alisa_skill/internal/resolver"
+	"github.com/VladimirAzanza/alisa_skill/internal/store/instrumented"
+	"github.com/VladimirAzanza/alisa_skill/internal/store/pg"
+	"go.uber.org/zap"
+)
+
+// skillConfig описывает один из навыков, обслуживаемых процессом.
+// Навыки различаются набором команд, часовым поясом и схемой базы данных;
+// язык у всех навыков один — русский, поэтому отдельной настройки
+// локали с текстами ответов нет.
+type skillConfig struct {
+	// ID совпадает с session.skill_id в запросах навыка.
+	ID string `json:"id"`
+	// Path задаёт путь вебхука навыка; пустой путь означает маршрутизацию по ID.
+	Path string `json:"path"`
+	// Schema задаёт схему базы данных, в которой хранятся данные навыка.
+	// Пустая схема означает схему по умолчанию из строки подключения.
+	Schema string `json:"schema"`
+	// Timezone — часовой пояс навыка в формате IANA, например "Europe/Moscow".
+	// Используется, если Алиса не передала часовой пояс пользователя.
+	Timezone string `json:"timezone"`
+	// DisabledCommands перечисляет начала команд, недоступных в навыке.
+	DisabledCommands []string `json:"disabled_commands"`
+}
+
+// loadSkills читает описание навыков из JSON-файла со списком skillConfig.
+// Навыки не могут делить ID, путь или схему: иначе пользователи одного
+// навыка увидели бы данные другого.
+func loadSkills(path string) ([]skillConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var skills []skillConfig
+	if err := json.Unmarshal(data, &skills); err != nil {
+		return nil, fmt.Errorf("cannot parse skills config: %w", err)
+	}
+
+	ids := make(map[string]bool)
+	paths := make(map[string]bool)
+	schemas := make(map[string]bool)
+	for _, skill := range skills {
+		switch {
+		case skill.ID == "":
+			return nil, fmt.Errorf("skill without id in %s", path)
+		case ids[skill.ID]:
+			return nil, fmt.Errorf("duplicate skill id %q", skill.ID)
+		case skill.Path != "" && paths[skill.Path]:
+			return nil, fmt.Errorf("duplicate skill path %q", skill.Path)
+		case schemas[skill.Schema]:
+			return nil, fmt.Errorf("skills share database schema %q", skill.Schema)
+		}
+		ids[skill.ID] = true
+		paths[skill.Path] = true
+		schemas[skill.Schema] = true
+	}
+	return skills, nil
+}
+
+// skillRouter передаёт запрос вебхуку навыка, выбранному по пути URL
+// или, если путь не назначен ни одному навыку, по session.skill_id.
+// Запрос на путь навыка принимается, только если session.skill_id
+// принадлежит этому же навыку: иначе один навык мог бы читать данные другого.
+type skillRouter struct {
+	// byPath сопоставляет путь URL с ID навыка.
+	byPath    map[string]string
+	bySkillID map[string]http.HandlerFunc
+}
+
+func (rt skillRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		logger.FromContext(r.Context()).Debug("cannot read request body", zap.Error(err))
+		w.WriteHeader(bodyErrorStatus(err))
+		return
+	}
+
+	skillID := requestSkillID(body)
+	if pathSkillID, ok := rt.byPath[r.URL.Path]; ok && skillID != pathSkillID {
+		logger.FromContext(r.Context()).Warn("skill ID does not match webhook path",
+			zap.String("event", "security"),
+			zap.String("skill_id", skillID),
+			zap.String("path", r.URL.Path),
+		)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	h, ok := rt.bySkillID[skillID]
+	if !ok {
+		logger.FromContext(r.Context()).Info("request for unknown skill", zap.String("skill_id", skillID), zap.String("path", r.URL.Path))
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	h(w, r)
+}
+
+// newSkillRouter создаёт по экземпляру навыка на каждый skillConfig.
+// Настройки base общие для всех навыков; каждый навык работает
+// со своей схемой базы данных. Экземпляры навыков возвращаются по их ID.
+func newSkillRouter(dsn string, base appConfig, skills []skillConfig) (*skillRouter, map[string]*app, error) {
+	rt := &skillRouter{
+		byPath:    make(map[string]string),
+		bySkillID: make(map[string]http.HandlerFunc),
+	}
+	apps := make(map[string]*app)
+
+	for _, skill := range skills {
+		config := base
+		config.disabledCommands = skill.DisabledCommands
+		if skill.Timezone != "" {
+			loc, err := time.LoadLocation(skill.Timezone)
+			if err != nil {
+				return nil, nil, fmt.Errorf("skill %s: %w", skill.ID, err)
+			}
+			config.location = loc
+		}
+
+		skillDSN := dsn
+		if skill.Schema != "" {
+			var err error
+			if skillDSN, err = pg.TenantDSN(dsn, skill.Schema); err != nil {
+				return nil, nil, fmt.Errorf("skill %s: %w", skill.ID, err)
+			}
+		}
+		conn, err := sql.Open("pgx", skillDSN)
+		if err != nil {
+			return nil, nil, fmt.Errorf("skill %s: %w", skill.ID, err)
+		}
+
+		if skill.Schema != "" {
+			if err := pg.CreateSchema(context.Background(), conn, skill.Schema); err != nil {
+				return nil, nil, fmt.Errorf("skill %s: %w", skill.ID, err)
+			}
+		}
+		pgStore := pg.NewStore(conn)
+		if err := pgStore.Bootstrap(context.Background()); err != nil {
+			return nil, nil, fmt.Errorf("skill %s: %w", skill.ID, err)
+		}
+		apps[skill.ID] = newApp(instrumented.New(pgStore, storeHook), config)
+		webhook := apps[skill.ID].webhook
+		rt.bySkillID[skill.ID] = webhook
+		if skill.Path != "" {
+			rt.byPath[skill.Path] = skill.ID
+		}
+		logger.Log.Info("serving skill", zap.String("skill_id", skill.ID), zap.String("path", skill.Path), zap.String("schema", skill.Schema))
+	}
+	return rt, apps, nil
+}
+
+// readBody читает тело запроса целиком и подменяет его копией,
+// чтобы следующий обработчик мог прочитать его снова.
+func readBody(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// requestSkillID возвращает session.skill_id из тела запроса Алисы
+// или пустую строку, если тело не разбирается.
+func requestSkillID(body []byte) string {
+	var req struct {
+		Session struct {
+			SkillID string `json:"skill_id"`
+		} `json:"session"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return ""
+	}
+	return req.Session.SkillID
+}
+
+// commandDisabled сообщает, отключена ли команда в настройках навыка.
+func (s *app) commandDisabled(command string) bool {
+	command = resolver.Normalize(command)
+	for _, disabled := range s.config.disabledCommands {
+		if strings.HasPrefix(command, resolver.Normalize(disabled)) {
+			return true
+		}
+	}
+	return false
+}
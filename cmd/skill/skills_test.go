@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSkills(t *testing.T) {
+	write := func(t *testing.T, data string) string {
+		path := filepath.Join(t.TempDir(), "skills.json")
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+		return path
+	}
+
+	skills, err := loadSkills(write(t, `[
+		{"id": "personal", "schema": "personal"},
+		{"id": "corporate", "path": "/corporate", "schema": "corporate", "timezone": "Europe/Moscow", "disabled_commands": ["Напомни"]}
+	]`))
+	require.NoError(t, err)
+	assert.Len(t, skills, 2)
+	assert.Equal(t, []string{"Напомни"}, skills[1].DisabledCommands)
+
+	_, err = loadSkills(write(t, `[{"id": "a", "schema": "shared"}, {"id": "b", "schema": "shared"}]`))
+	assert.Error(t, err, "skills must not share a schema")
+
+	_, err = loadSkills(write(t, `[{"id": "a", "schema": "a"}, {"id": "a", "schema": "b"}]`))
+	assert.Error(t, err, "skill ids must be unique")
+}
+
+func TestSkillRouter(t *testing.T) {
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}
+	}
+	rt := skillRouter{
+		byPath:    map[string]string{"/corporate": "corporate-id"},
+		bySkillID: map[string]http.HandlerFunc{"personal-id": handler("personal"), "corporate-id": handler("corporate")},
+	}
+
+	testCases := []struct {
+		name         string
+		path         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "by_path", path: "/corporate", body: `{"session": {"skill_id": "corporate-id"}}`, expectedCode: http.StatusOK, expectedBody: "corporate"},
+		{name: "path_of_other_skill", path: "/corporate", body: `{"session": {"skill_id": "personal-id"}}`, expectedCode: http.StatusForbidden},
+		{name: "path_without_skill_id", path: "/corporate", body: `{}`, expectedCode: http.StatusForbidden},
+		{name: "by_skill_id", path: "/", body: `{"session": {"skill_id": "personal-id"}}`, expectedCode: http.StatusOK, expectedBody: "personal"},
+		{name: "unknown_skill", path: "/", body: `{"session": {"skill_id": "other"}}`, expectedCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rt.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body)))
+
+			assert.Equal(t, tc.expectedCode, w.Code)
+			assert.Equal(t, tc.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestCommandDisabled(t *testing.T) {
+	s := &app{config: appConfig{disabledCommands: []string{"Напомни", "Создай группу"}}}
+
+	assert.True(t, s.commandDisabled("напомни мне завтра купить хлеб"))
+	assert.True(t, s.commandDisabled("Создай  группу семья"))
+	assert.False(t, s.commandDisabled("Отправь Маше привет"))
+}
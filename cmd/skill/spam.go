@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/plural"
+	"github.com/VladimirAzanza/alisa_skill/internal/ratelimit"
+	"github.com/VladimirAzanza/alisa_skill/internal/resolver"
+)
+
+// limitsConfig задаёт защиту от спама. Нулевые значения снимают соответствующее ограничение.
+type limitsConfig struct {
+	// window задаёт окно, в котором считаются сообщения отправителя и получателя.
+	window time.Duration
+	// senderRate ограничивает число отправок одного пользователя за окно.
+	senderRate int
+	// recepientRate ограничивает число сообщений, которые один пользователь получает за окно.
+	recepientRate int
+	// duplicates ограничивает число отправок одинакового текста за duplicateWindow.
+	duplicates      int
+	duplicateWindow time.Duration
+	// maxPayload ограничивает длину сообщения в символах.
+	maxPayload int
+}
+
+// spamGuard проверяет сообщения перед постановкой в очередь отправки.
+type spamGuard struct {
+	config     limitsConfig
+	senders    *ratelimit.Limiter
+	recepients *ratelimit.Limiter
+	duplicates *ratelimit.Limiter
+}
+
+func newSpamGuard(config limitsConfig) *spamGuard {
+	return &spamGuard{
+		config:     config,
+		senders:    ratelimit.New(config.senderRate, config.window),
+		recepients: ratelimit.New(config.recepientRate, config.window),
+		duplicates: ratelimit.New(config.duplicates, config.duplicateWindow),
+	}
+}
+
+// checkSend учитывает отправку сообщения payload пользователем senderID
+// и возвращает причину отказа для озвучивания или пустую строку.
+func (g *spamGuard) checkSend(senderID, payload string, now time.Time) string {
+	if length := len([]rune(payload)); g.config.maxPayload > 0 && length > g.config.maxPayload {
+		return fmt.Sprintf("Сообщение слишком длинное, можно не больше %d %s.",
+			g.config.maxPayload, plural.Form(g.config.maxPayload, "символ", "символа", "символов"))
+	}
+	if !g.senders.Allow(senderID, now) {
+		return "Вы отправляете слишком много сообщений. Попробуйте немного позже."
+	}
+	// одинаковым считается текст, совпадающий без учёта регистра и лишних пробелов
+	if !g.duplicates.Allow(senderID+"\n"+resolver.Normalize(payload), now) {
+		return "Вы уже несколько раз отправили это сообщение. Повторять его пока нельзя."
+	}
+	return ""
+}
+
+// allowRecepient учитывает сообщение для получателя recepientID и сообщает,
+// не превышен ли его лимит входящих.
+func (g *spamGuard) allowRecepient(recepientID string, now time.Time) bool {
+	return g.recepients.Allow(recepientID, now)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpamGuard(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("payload", func(t *testing.T) {
+		g := newSpamGuard(limitsConfig{maxPayload: 10})
+		assert.Empty(t, g.checkSend("иван", "Привет!", now))
+		assert.Equal(t, "Сообщение слишком длинное, можно не больше 10 символов.", g.checkSend("иван", strings.Repeat("а", 11), now))
+	})
+
+	t.Run("sender", func(t *testing.T) {
+		g := newSpamGuard(limitsConfig{window: time.Minute, senderRate: 2})
+		assert.Empty(t, g.checkSend("иван", "раз", now))
+		assert.Empty(t, g.checkSend("иван", "два", now))
+		assert.NotEmpty(t, g.checkSend("иван", "три", now))
+		assert.Empty(t, g.checkSend("маша", "три", now))
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		g := newSpamGuard(limitsConfig{duplicates: 1, duplicateWindow: time.Minute})
+		assert.Empty(t, g.checkSend("иван", "Купи слона", now))
+		assert.NotEmpty(t, g.checkSend("иван", "купи  слона", now))
+		assert.Empty(t, g.checkSend("иван", "Купи слона", now.Add(2*time.Minute)))
+	})
+
+	t.Run("recepient", func(t *testing.T) {
+		g := newSpamGuard(limitsConfig{window: time.Minute, recepientRate: 1})
+		assert.True(t, g.allowRecepient("маша", now))
+		assert.False(t, g.allowRecepient("маша", now))
+	})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/store"
+	"github.com/VladimirAzanza/alisa_skill/internal/store/instrumented"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queuedMessage — сообщение, ожидающее записи, вместе с контекстом запроса,
+// который его отправил: спан записи ссылается на эти запросы.
+type queuedMessage struct {
+	store.Message
+	span trace.SpanContext
+}
+
+// errQueueFull означает, что очередь на запись заполнена и сообщение не принято.
+var errQueueFull = errors.New("message queue is full")
+
+// queueFullText отвечает пользователю, если сообщение не удалось поставить в очередь.
+const queueFullText = "Не получилось отправить сообщение. Попробуйте немного позже."
+
+// enqueue ставит сообщение в очередь на запись. Enqueue не ждёт, пока
+// в очереди освободится место, и не ставит сообщение отменённого запроса:
+// иначе зависший обработчик держал бы соединение до конца таймаута.
+func (s *app) enqueue(ctx context.Context, msg store.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case s.msgChan <- queuedMessage{Message: msg, span: trace.SpanContextFromContext(ctx)}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+		return errQueueFull
+	}
+}
+
+// storeHook измеряет и трассирует обращения к хранилищу.
+var storeHook = instrumented.Chain(storeMetrics, storeTracing)
+
+// tracerName называет инструментирующую библиотеку в спанах навыка.
+const tracerName = "github.com/VladimirAzanza/alisa_skill/cmd/skill"
+
+// tracer берёт трассировщик у глобального провайдера при каждом вызове,
+// чтобы спаны попадали в провайдер, установленный после запуска пакета.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// tracerProvider создаёт провайдер спанов с экспортёром из флагов.
+// Возвращает nil, если трассировка выключена.
+func tracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	exporter, err := spanExporter(ctx)
+	if err != nil || exporter == nil {
+		return nil, err
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(flagServiceName))
+	return sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res)), nil
+}
+
+// setSpanError отмечает спан как завершившийся ошибкой.
+func setSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// storeTracing записывает спан на каждое обращение к хранилищу.
+func storeTracing(ctx context.Context, method string) (context.Context, func(err error)) {
+	ctx, span := tracer().Start(ctx, "store."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", method),
+		),
+	)
+	return ctx, func(err error) {
+		if err != nil && !errors.Is(err, store.ErrNotFound) && !errors.Is(err, store.ErrConflict) {
+			setSpanError(span, err)
+		}
+		span.End()
+	}
+}
+
+// tracingMiddleware продолжает трассу из заголовка traceparent или начинает
+// новую и записывает спан запроса с кодом ответа и распознанной командой.
+func tracingMiddleware(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer().Start(ctx, "webhook", trace.WithSpanKind(trace.SpanKindServer))
+		defer span.End()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		h(rec, r.WithContext(ctx))
+
+		span.SetAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+			attribute.Int("http.response.status_code", rec.Status()),
+		)
+		if info := requestInfoFrom(ctx); info != nil {
+			span.SetAttributes(attribute.String("skill.command", info.Command()))
+		}
+		if rec.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.Status()))
+		}
+	}
+}
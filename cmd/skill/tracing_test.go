@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	a := &app{msgChan: make(chan queuedMessage, 1)}
+	handler := metricsMiddleware(tracingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		setCommand(r.Context(), "send")
+		a.enqueue(r.Context(), store.Message{Payload: "привет"})
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler(httptest.NewRecorder(), r)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "webhook", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Contains(t, span.Attributes(), attribute.String("skill.command", "send"))
+	assert.Contains(t, span.Attributes(), attribute.Int("http.response.status_code", http.StatusInternalServerError))
+	assert.Equal(t, codes.Error, span.Status().Code)
+
+	// сообщение помнит запрос, чтобы спан записи мог на него сослаться
+	msg := <-a.msgChan
+	assert.Equal(t, "привет", msg.Payload)
+	assert.Equal(t, span.SpanContext(), msg.span)
+}
+
+func TestEnqueue(t *testing.T) {
+	a := &app{msgChan: make(chan queuedMessage, 1)}
+
+	require.NoError(t, a.enqueue(context.Background(), store.Message{Payload: "первое"}))
+	// очередь заполнена: enqueue не ждёт, а сразу сообщает об ошибке
+	assert.ErrorIs(t, a.enqueue(context.Background(), store.Message{Payload: "второе"}), errQueueFull)
+
+	<-a.msgChan
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, a.enqueue(ctx, store.Message{Payload: "третье"}), context.Canceled)
+	assert.Empty(t, a.msgChan)
+}
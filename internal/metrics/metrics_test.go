@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	r := &Registry{}
+
+	requests := r.NewCounterVec("requests_total", "Число запросов.", "command", "status")
+	requests.With("send", "200").Inc()
+	requests.With("send", "200").Inc()
+	requests.With("read", "500").Add(3)
+
+	latency := r.NewHistogramVec("latency_seconds", "Задержка.", []float64{0.1, 1}, "method")
+	latency.With("Ping").Observe(0.05)
+	latency.With("Ping").Observe(0.5)
+
+	r.NewGaugeFunc("queue_depth", "Длина очереди.", func() float64 { return 7 })
+
+	var b strings.Builder
+	_, err := r.WriteTo(&b)
+	require.NoError(t, err)
+
+	assert.Equal(t, `# HELP requests_total Число запросов.
+# TYPE requests_total counter
+requests_total{command="read",status="500"} 3
+requests_total{command="send",status="200"} 2
+# HELP latency_seconds Задержка.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{method="Ping",le="0.1"} 1
+latency_seconds_bucket{method="Ping",le="1"} 2
+latency_seconds_bucket{method="Ping",le="+Inf"} 2
+latency_seconds_sum{method="Ping"} 0.55
+latency_seconds_count{method="Ping"} 2
+# HELP queue_depth Длина очереди.
+# TYPE queue_depth gauge
+queue_depth 7
+`, b.String())
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := &Registry{}
+	r.NewCounterVec("errors_total", "Ошибки.", "reason").With("bad \"quote\"\n").Inc()
+
+	var b strings.Builder
+	r.WriteTo(&b)
+	assert.Contains(t, b.String(), `errors_total{reason="bad \"quote\"\n"} 1`)
+}
+
+func TestCounter(t *testing.T) {
+	r := &Registry{}
+	purged := r.NewCounterVec("purged_total", "Удалено.").With()
+
+	var b strings.Builder
+	r.WriteTo(&b)
+	assert.Contains(t, b.String(), "\npurged_total 0\n")
+
+	purged.Add(5)
+	assert.Equal(t, 5.0, purged.Value())
+}
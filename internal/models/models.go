@@ -1,7 +1,20 @@
 package models
 
+import "encoding/json"
+
+const (
+	TypeSimpleUtterance        = "SimpleUtterance"
+	TypeAccountLinkingComplete = "AccountLinkingComplete"
+)
+
+const (
+	EntityFIO      = "YANDEX.FIO"
+	EntityDateTime = "YANDEX.DATETIME"
+)
+
 const (
-	TypeSimpleUtterance = "SimpleUtterance"
+	IntentConfirm = "YANDEX.CONFIRM"
+	IntentReject  = "YANDEX.REJECT"
 )
 
 // Request описывает запрос пользователя.
@@ -10,32 +23,161 @@ type Request struct {
 	Timezone string          `json:"timezone"`
 	Request  SimpleUtterance `json:"request"`
 	Session  Session         `json:"session"`
+	State    State           `json:"state"`
 	Version  string          `json:"version"`
+	// AccountLinkingCompleteEvent передаётся после успешной привязки аккаунта.
+	AccountLinkingCompleteEvent *AccountLinking `json:"account_linking_complete_event,omitempty"`
 }
 
 type Session struct {
-	New  bool `json:"new"`
-	User User `json:"user"`
+	New         bool        `json:"new"`
+	SessionID   string      `json:"session_id"`
+	MessageID   int64       `json:"message_id"`
+	SkillID     string      `json:"skill_id"`
+	User        User        `json:"user"`
+	Application Application `json:"application"`
 }
 
+// User описывает пользователя, вошедшего в аккаунт Яндекса.
+// Для неавторизованных пользователей Алиса его не передаёт.
 type User struct {
-	UserID string `json:"userID"`
+	UserID string `json:"user_id"`
+}
+
+// Application описывает экземпляр приложения, через которое пользователь
+// общается с Алисой. Передаётся всегда, в том числе без авторизации.
+type Application struct {
+	ApplicationID string `json:"application_id"`
+}
+
+// State описывает сохранённое состояние навыка.
+// см. https://yandex.ru/dev/dialogs/alice/doc/session-persistence.html
+type State struct {
+	Session SessionState `json:"session"`
+}
+
+// SessionState описывает состояние, сохраняемое в рамках сессии.
+type SessionState struct {
+	LastReadID int64 `json:"last_read_id,omitempty"`
+	// PendingRecepients и PendingMessage хранят неотправленное сообщение,
+	// пока пользователь уточняет, кого из похожих получателей он имел в виду.
+	PendingRecepients []string `json:"pending_recepients,omitempty"`
+	PendingMessage    string   `json:"pending_message,omitempty"`
+	// ReadAll выставляется, когда навык прочитал часть непрочитанных сообщений
+	// и спросил, продолжать ли.
+	ReadAll bool `json:"read_all,omitempty"`
+	// ConfirmDeletion выставляется, когда навык ждёт подтверждения удаления аккаунта.
+	ConfirmDeletion bool `json:"confirm_deletion,omitempty"`
 }
 
 // SimpleUtterance описывает команду, полученную в запросе типа SimpleUtterance.
 type SimpleUtterance struct {
 	Type    string `json:"type"`
 	Command string `json:"command"`
+	Nlu     Nlu    `json:"nlu"`
+	Markup  Markup `json:"markup"`
+}
+
+// Markup описывает формальные характеристики реплики пользователя.
+type Markup struct {
+	// DangerousContext выставляется, если реплика похожа на высказывание,
+	// требующее особого отношения, например о насилии или суициде.
+	DangerousContext bool `json:"dangerous_context"`
+}
+
+// Nlu описывает результат разбора команды на естественном языке.
+// см. https://yandex.ru/dev/dialogs/alice/doc/ru/nlu
+type Nlu struct {
+	Tokens   []string                   `json:"tokens"`
+	Entities []Entity                   `json:"entities"`
+	Intents  map[string]json.RawMessage `json:"intents"`
+}
+
+// Entity описывает именованную сущность, найденную в команде.
+// Формат Value зависит от типа сущности.
+type Entity struct {
+	Type   string          `json:"type"`
+	Tokens EntityTokens    `json:"tokens"`
+	Value  json.RawMessage `json:"value"`
+}
+
+// EntityTokens задаёт положение сущности в Nlu.Tokens: [Start, End).
+type EntityTokens struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// DateTime описывает значение сущности типа YANDEX.DATETIME.
+// Отсутствующие поля равны nil; относительные значения задают смещение от текущего момента.
+type DateTime struct {
+	Year             *int `json:"year"`
+	YearIsRelative   bool `json:"year_is_relative"`
+	Month            *int `json:"month"`
+	MonthIsRelative  bool `json:"month_is_relative"`
+	Day              *int `json:"day"`
+	DayIsRelative    bool `json:"day_is_relative"`
+	Hour             *int `json:"hour"`
+	HourIsRelative   bool `json:"hour_is_relative"`
+	Minute           *int `json:"minute"`
+	MinuteIsRelative bool `json:"minute_is_relative"`
+}
+
+// FIO описывает значение сущности типа YANDEX.FIO.
+type FIO struct {
+	FirstName      string `json:"first_name"`
+	PatronymicName string `json:"patronymic_name"`
+	LastName       string `json:"last_name"`
 }
 
 // Response описывает ответ сервера.
 // см. https://yandex.ru/dev/dialogs/alice/doc/response.html
 type Response struct {
-	Response ResponsePayload `json:"response"`
-	Version  string          `json:"version"`
+	Response     ResponsePayload `json:"response"`
+	SessionState SessionState    `json:"session_state"`
+	Version      string          `json:"version"`
+	// StartAccountLinking просит Алису предложить пользователю привязать аккаунт.
+	StartAccountLinking *AccountLinking `json:"start_account_linking,omitempty"`
 }
 
+// AccountLinking — пустой объект директивы и события привязки аккаунта.
+// см. https://yandex.ru/dev/dialogs/alice/doc/ru/auth/make-skill
+type AccountLinking struct{}
+
 // ResponsePayload описывает ответ, который нужно озвучить.
 type ResponsePayload struct {
 	Text string `json:"text"`
+	// TTS задаёт озвучиваемый текст с разметкой пауз, если он отличается от Text.
+	TTS  string `json:"tts,omitempty"`
+	Card *Card  `json:"card,omitempty"`
+}
+
+// Ограничения длины ответа.
+// см. https://yandex.ru/dev/dialogs/alice/doc/ru/response
+const (
+	MaxTextLength = 1024
+	MaxTTSLength  = 1024
+)
+
+const (
+	CardItemsList = "ItemsList"
+)
+
+// Card описывает карточку, которая показывается на устройствах с экраном.
+// см. https://yandex.ru/dev/dialogs/alice/doc/ru/response-card-itemslist
+type Card struct {
+	Type   string      `json:"type"`
+	Header *CardHeader `json:"header,omitempty"`
+	Items  []CardItem  `json:"items,omitempty"`
+}
+
+// CardHeader описывает заголовок карточки.
+type CardHeader struct {
+	Text string `json:"text"`
+}
+
+// CardItem описывает элемент карточки-списка.
+type CardItem struct {
+	ImageID     string `json:"image_id,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
 }
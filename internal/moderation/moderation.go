@@ -0,0 +1,176 @@
+// Package moderation проверяет тексты сообщений перед отправкой.
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// ErrRejected возвращается, если сообщение нельзя отправить.
+var ErrRejected = errors.New("message rejected")
+
+// Moderator проверяет текст сообщения.
+type Moderator interface {
+	// Moderate возвращает текст, пригодный для доставки, возможно изменённый,
+	// или ErrRejected, если сообщение отправлять нельзя.
+	Moderate(ctx context.Context, text string) (string, error)
+}
+
+// DefaultWords содержит корни нецензурных слов для встроенного фильтра.
+var DefaultWords = []string{"хуй", "хуе", "пизд", "ебан", "ебат", "ебал", "ебну", "бляд", "блят", "мудак", "мудил"}
+
+// prefixes — приставки, после которых корень словаря тоже считается началом слова:
+// «заебал», «спиздил». Корень в середине слова не ищется, иначе под фильтр
+// попадают обычные слова вроде «застрахует».
+var prefixes = []string{
+	"за", "на", "по", "от", "до", "вы", "у", "о", "об", "объ", "с", "съ", "из", "ис",
+	"раз", "рас", "при", "про", "пере", "под", "подъ", "недо", "вз", "въ",
+}
+
+// Dictionary — фильтр по словарю корней. Слова, начинающиеся с корня,
+// в том числе после приставки, маскируются звёздочками,
+// а при Reject сообщение отклоняется целиком.
+type Dictionary struct {
+	Words  []string
+	Reject bool
+}
+
+func (d Dictionary) Moderate(_ context.Context, text string) (string, error) {
+	runes := []rune(text)
+	found := false
+
+	for start := 0; start < len(runes); {
+		if !unicode.IsLetter(runes[start]) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && unicode.IsLetter(runes[end]) {
+			end++
+		}
+		if d.matches(string(runes[start:end])) {
+			found = true
+			// первая буква остаётся, чтобы было понятно, что слово было
+			for i := start + 1; i < end; i++ {
+				runes[i] = '*'
+			}
+		}
+		start = end
+	}
+
+	if !found {
+		return text, nil
+	}
+	if d.Reject {
+		return "", ErrRejected
+	}
+	return string(runes), nil
+}
+
+func (d Dictionary) matches(word string) bool {
+	word = strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+	for _, root := range d.Words {
+		root = strings.ReplaceAll(strings.ToLower(root), "ё", "е")
+		if strings.HasPrefix(word, root) {
+			return true
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(word, prefix+root) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Chain применяет модераторы по очереди, передавая каждому текст,
+// изменённый предыдущим.
+type Chain []Moderator
+
+func (c Chain) Moderate(ctx context.Context, text string) (string, error) {
+	for _, m := range c {
+		var err error
+		if text, err = m.Moderate(ctx, text); err != nil {
+			return "", err
+		}
+	}
+	return text, nil
+}
+
+// Решения внешнего сервиса модерации.
+const (
+	ActionAllow  = "allow"
+	ActionMask   = "mask"
+	ActionReject = "reject"
+)
+
+// HTTPRequest — тело запроса к внешнему сервису модерации.
+type HTTPRequest struct {
+	Text string `json:"text"`
+}
+
+// HTTPResponse — ответ внешнего сервиса модерации. При ActionMask
+// поле Text содержит исправленный текст.
+type HTTPResponse struct {
+	Action string `json:"action"`
+	Text   string `json:"text,omitempty"`
+}
+
+// HTTP передаёт текст внешнему сервису модерации POST-запросом с HTTPRequest
+// и ожидает в ответ HTTPResponse. Время ожидания ограничивается клиентом и ctx.
+type HTTP struct {
+	URL    string
+	Client *http.Client
+}
+
+func (h HTTP) Moderate(ctx context.Context, text string) (string, error) {
+	body, err := json.Marshal(HTTPRequest{Text: text})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("moderation service returned %s", resp.Status)
+	}
+
+	var verdict HTTPResponse
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return "", err
+	}
+
+	switch verdict.Action {
+	case ActionAllow:
+		return text, nil
+	case ActionMask:
+		return verdict.Text, nil
+	case ActionReject:
+		return "", ErrRejected
+	}
+	return "", fmt.Errorf("unknown moderation action %q", verdict.Action)
+}
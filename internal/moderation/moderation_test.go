@@ -0,0 +1,93 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDictionary(t *testing.T) {
+	ctx := context.Background()
+
+	masked, err := Dictionary{Words: []string{"мудак"}}.Moderate(ctx, "Ты Мудак, Петя!")
+	require.NoError(t, err)
+	assert.Equal(t, "Ты М****, Петя!", masked)
+
+	clean, err := Dictionary{Words: DefaultWords}.Moderate(ctx, "Купи хлеба")
+	require.NoError(t, err)
+	assert.Equal(t, "Купи хлеба", clean)
+
+	_, err = Dictionary{Words: []string{"мудак"}, Reject: true}.Moderate(ctx, "ну ты и мудак")
+	assert.ErrorIs(t, err, ErrRejected)
+}
+
+func TestDictionaryMatchesWordStarts(t *testing.T) {
+	ctx := context.Background()
+	d := Dictionary{Words: DefaultWords}
+
+	// корень внутри обычного слова не считается бранью
+	for _, text := range []string{"Он застрахует машину", "Ты перестрахуешься", "Страхуй квартиру", "Небанальный ход"} {
+		clean, err := d.Moderate(ctx, text)
+		require.NoError(t, err)
+		assert.Equal(t, text, clean)
+	}
+
+	masked, err := d.Moderate(ctx, "Он всех заебал")
+	require.NoError(t, err)
+	assert.Equal(t, "Он всех з*****", masked)
+}
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req HTTPRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch req.Text {
+		case "плохо":
+			json.NewEncoder(w).Encode(HTTPResponse{Action: ActionReject})
+		case "так себе":
+			json.NewEncoder(w).Encode(HTTPResponse{Action: ActionMask, Text: "так ***"})
+		case "ошибка":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			json.NewEncoder(w).Encode(HTTPResponse{Action: ActionAllow})
+		}
+	}))
+	defer srv.Close()
+
+	m := HTTP{URL: srv.URL, Client: srv.Client()}
+	ctx := context.Background()
+
+	text, err := m.Moderate(ctx, "привет")
+	require.NoError(t, err)
+	assert.Equal(t, "привет", text)
+
+	text, err = m.Moderate(ctx, "так себе")
+	require.NoError(t, err)
+	assert.Equal(t, "так ***", text)
+
+	_, err = m.Moderate(ctx, "плохо")
+	assert.ErrorIs(t, err, ErrRejected)
+
+	_, err = m.Moderate(ctx, "ошибка")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrRejected)
+}
+
+func TestChain(t *testing.T) {
+	c := Chain{Dictionary{Words: []string{"мудак"}}, Dictionary{Words: []string{"петя"}, Reject: true}}
+
+	text, err := c.Moderate(context.Background(), "мудак")
+	require.NoError(t, err)
+	assert.Equal(t, "м****", text)
+
+	_, err = c.Moderate(context.Background(), "Петя")
+	assert.ErrorIs(t, err, ErrRejected)
+}
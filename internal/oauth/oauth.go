@@ -0,0 +1,230 @@
+// Package oauth реализует минимальный сервер авторизации OAuth 2.0
+// (authorization code) для привязки аккаунтов в навыках Алисы.
+// см. https://yandex.ru/dev/dialogs/alice/doc/ru/auth/when-to-use
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// codeTTL ограничивает время, за которое код авторизации нужно обменять на токен.
+const codeTTL = 10 * time.Minute
+
+// Directory проверяет учётные данные пользователя в каталоге организации.
+type Directory interface {
+	Authenticate(login, password string) bool
+}
+
+// StaticDirectory — каталог из фиксированного списка логинов и паролей
+// для локальной проверки привязки аккаунтов.
+type StaticDirectory map[string]string
+
+func (d StaticDirectory) Authenticate(login, password string) bool {
+	expected, ok := d[login]
+	return ok && subtle.ConstantTimeCompare([]byte(expected), []byte(password)) == 1
+}
+
+// TokenStore сохраняет выданные токены. Сохраняется только хеш токена.
+type TokenStore interface {
+	SaveToken(ctx context.Context, tokenHash, account string) error
+}
+
+// HashToken возвращает хеш, под которым токен хранится и ищется.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Server выдаёт коды авторизации через Authorize и обменивает их
+// на бессрочные токены доступа через Token.
+type Server struct {
+	ClientID     string
+	ClientSecret string
+	// RedirectURIs перечисляет адреса, на которые разрешено возвращать код.
+	RedirectURIs []string
+	Directory    Directory
+	Tokens       TokenStore
+
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+// grant описывает выданный, но ещё не обменянный код авторизации.
+type grant struct {
+	account     string
+	redirectURI string
+	expiresAt   time.Time
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head><meta charset="utf-8"><title>Вход</title></head>
+<body>
+<form method="post">
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<input type="hidden" name="client_id" value="{{.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+<input type="hidden" name="response_type" value="code">
+<input type="hidden" name="state" value="{{.State}}">
+<p><label>Логин <input name="login" autocomplete="username"></label></p>
+<p><label>Пароль <input name="password" type="password" autocomplete="current-password"></label></p>
+<p><button type="submit">Войти</button></p>
+</form>
+</body>
+</html>
+`))
+
+// Authorize показывает форму входа и после успешной проверки в каталоге
+// перенаправляет пользователя на redirect_uri с кодом авторизации.
+func (s *Server) Authorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	clientID := r.Form.Get("client_id")
+	redirectURI := r.Form.Get("redirect_uri")
+	state := r.Form.Get("state")
+	// на неизвестный адрес нельзя перенаправлять даже с ошибкой
+	if clientID != s.ClientID || !slices.Contains(s.RedirectURIs, redirectURI) {
+		http.Error(w, "unknown client or redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("response_type") != "code" {
+		redirect(w, r, redirectURI, url.Values{"error": {"unsupported_response_type"}, "state": {state}})
+		return
+	}
+
+	page := struct {
+		ClientID, RedirectURI, State, Error string
+	}{ClientID: clientID, RedirectURI: redirectURI, State: state}
+
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		loginPage.Execute(w, page)
+		return
+	}
+
+	login := r.PostForm.Get("login")
+	if !s.Directory.Authenticate(login, r.PostForm.Get("password")) {
+		page.Error = "Неверный логин или пароль."
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusUnauthorized)
+		loginPage.Execute(w, page)
+		return
+	}
+
+	code := randomToken()
+	s.mu.Lock()
+	if s.codes == nil {
+		s.codes = make(map[string]grant)
+	}
+	// уберём коды, которые так и не обменяли
+	for c, g := range s.codes {
+		if time.Now().After(g.expiresAt) {
+			delete(s.codes, c)
+		}
+	}
+	s.codes[code] = grant{account: login, redirectURI: redirectURI, expiresAt: time.Now().Add(codeTTL)}
+	s.mu.Unlock()
+
+	redirect(w, r, redirectURI, url.Values{"code": {code}, "state": {state}})
+}
+
+// tokenResponse — ответ на успешный обмен кода по RFC 6749, раздел 5.1.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// Token обменивает код авторизации на токен доступа.
+func (s *Server) Token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != s.ClientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(s.ClientSecret)) != 1 {
+		tokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	// код одноразовый: удаляем его сразу, даже если обмен не удастся
+	code := r.PostForm.Get("code")
+	s.mu.Lock()
+	g, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+
+	redirectURI := r.PostForm.Get("redirect_uri")
+	if !ok || time.Now().After(g.expiresAt) || (redirectURI != "" && redirectURI != g.redirectURI) {
+		tokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	token := randomToken()
+	if err := s.Tokens.SaveToken(r.Context(), HashToken(token), g.account); err != nil {
+		tokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(tokenResponse{AccessToken: token, TokenType: "bearer"})
+}
+
+func redirect(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	query := u.Query()
+	for key, values := range params {
+		if values[0] != "" {
+			query[key] = values
+		}
+	}
+	u.RawQuery = query.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func tokenError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,91 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tokenStore map[string]string
+
+func (s tokenStore) SaveToken(_ context.Context, tokenHash, account string) error {
+	s[tokenHash] = account
+	return nil
+}
+
+func TestServer(t *testing.T) {
+	const redirectURI = "https://social.yandex.net/broker/redirect"
+
+	tokens := tokenStore{}
+	srv := &Server{
+		ClientID:     "alice",
+		ClientSecret: "secret",
+		RedirectURIs: []string{redirectURI},
+		Directory:    StaticDirectory{"ivanov": "pa55"},
+		Tokens:       tokens,
+	}
+
+	authorize := func(form url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		srv.Authorize(w, r)
+		return w
+	}
+	exchange := func(form url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		srv.Token(w, r)
+		return w
+	}
+
+	form := url.Values{
+		"client_id":     {"alice"},
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"state":         {"xyz"},
+		"login":         {"ivanov"},
+		"password":      {"wrong"},
+	}
+
+	w := httptest.NewRecorder()
+	srv.Authorize(w, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+form.Encode(), nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `name="state" value="xyz"`)
+
+	assert.Equal(t, http.StatusUnauthorized, authorize(form).Code)
+
+	evil := url.Values{"client_id": {"alice"}, "redirect_uri": {"https://evil.example/"}, "response_type": {"code"}}
+	assert.Equal(t, http.StatusBadRequest, authorize(evil).Code)
+
+	form.Set("password", "pa55")
+	w = authorize(form)
+	require.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "xyz", location.Query().Get("state"))
+	code := location.Query().Get("code")
+	require.NotEmpty(t, code)
+
+	w = exchange(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {"alice"}, "client_secret": {"wrong"}})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = exchange(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {"alice"}, "client_secret": {"secret"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp tokenResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, "bearer", resp.TokenType)
+	assert.Equal(t, "ivanov", tokens[HashToken(resp.AccessToken)])
+
+	// код нельзя использовать повторно
+	w = exchange(url.Values{"grant_type": {"authorization_code"}, "code": {code}, "client_id": {"alice"}, "client_secret": {"secret"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,14 @@
+// Package plural согласует русские слова с числительными.
+package plural
+
+// Form выбирает форму слова, согласованную с числом n:
+// one — «1 сообщение», few — «2 сообщения», many — «5 сообщений».
+func Form(n int, one, few, many string) string {
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return one
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 10 || n%100 >= 20):
+		return few
+	}
+	return many
+}
@@ -0,0 +1,27 @@
+package plural
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForm(t *testing.T) {
+	testCases := []struct {
+		n        int
+		expected string
+	}{
+		{n: 1, expected: "символ"},
+		{n: 21, expected: "символ"},
+		{n: 2, expected: "символа"},
+		{n: 24, expected: "символа"},
+		{n: 5, expected: "символов"},
+		{n: 11, expected: "символов"},
+		{n: 12, expected: "символов"},
+		{n: 0, expected: "символов"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, Form(tc.n, "символ", "символа", "символов"), tc.n)
+	}
+}
@@ -0,0 +1,74 @@
+// Package ratelimit ограничивает частоту действий скользящим окном в памяти процесса.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter разрешает не больше limit действий по одному ключу за окно window.
+// Нулевой или отрицательный limit снимает ограничение.
+type Limiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	hits      map[string][]time.Time
+	lastSweep time.Time
+}
+
+// New создаёт ограничитель с заданным числом действий за окно.
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:  limit,
+		window: window,
+		hits:   make(map[string][]time.Time),
+	}
+}
+
+// Allow учитывает действие по ключу key в момент now и сообщает,
+// укладывается ли оно в ограничение. Отклонённые действия не учитываются.
+func (l *Limiter) Allow(key string, now time.Time) bool {
+	if l.limit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	hits := l.recent(key, now)
+	if len(hits) >= l.limit {
+		l.hits[key] = hits
+		return false
+	}
+	l.hits[key] = append(hits, now)
+	return true
+}
+
+// recent возвращает действия по ключу, попадающие в окно, заканчивающееся в now.
+func (l *Limiter) recent(key string, now time.Time) []time.Time {
+	hits := l.hits[key]
+	i := 0
+	for i < len(hits) && !hits[i].After(now.Add(-l.window)) {
+		i++
+	}
+	return hits[i:]
+}
+
+// sweep раз в окно удаляет ключи, по которым не было действий,
+// чтобы память не росла вместе с числом пользователей.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+	l.lastSweep = now
+	for key := range l.hits {
+		if hits := l.recent(key, now); len(hits) == 0 {
+			delete(l.hits, key)
+		} else {
+			l.hits[key] = hits
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := New(2, time.Minute)
+
+	assert.True(t, l.Allow("иван", now))
+	assert.True(t, l.Allow("иван", now.Add(10*time.Second)))
+	assert.False(t, l.Allow("иван", now.Add(20*time.Second)))
+	assert.True(t, l.Allow("маша", now.Add(20*time.Second)), "keys are limited independently")
+
+	// первое действие вышло из окна
+	assert.True(t, l.Allow("иван", now.Add(61*time.Second)))
+	assert.False(t, l.Allow("иван", now.Add(62*time.Second)))
+}
+
+func TestLimiterSweep(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	l := New(1, time.Minute)
+
+	l.Allow("иван", now)
+	l.Allow("маша", now.Add(2*time.Minute))
+
+	assert.Len(t, l.hits, 1)
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	l := New(0, time.Minute)
+	for range 100 {
+		assert.True(t, l.Allow("иван", time.Now()))
+	}
+}
@@ -0,0 +1,201 @@
+// Package resolver сопоставляет имена, произнесённые пользователем,
+// с зарегистрированными именами с учётом падежей и ошибок распознавания речи.
+package resolver
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// endings содержит падежные окончания, отсортированные по убыванию длины,
+// чтобы сначала отбрасывались самые длинные.
+var endings = []string{
+	"ами", "ями", "ого", "его", "ому", "ему",
+	"ой", "ей", "ом", "ем", "ам", "ям", "ах", "ях", "ою", "ею",
+	"а", "я", "у", "ю", "е", "и", "ы", "о", "ь",
+}
+
+// voiced задаёт оглушение согласных для фонетического сравнения.
+var voiced = strings.NewReplacer(
+	"б", "п", "в", "ф", "г", "к", "д", "т", "ж", "ш", "з", "с",
+	"ъ", "", "ь", "",
+)
+
+// Normalize приводит имя к нижнему регистру, заменяет «ё» на «е»
+// и убирает лишние пробелы.
+func Normalize(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.ReplaceAll(name, "ё", "е")
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Stem отбрасывает падежное окончание нормализованного имени.
+// Основа короче двух букв не укорачивается.
+func Stem(name string) string {
+	name = Normalize(name)
+	for _, ending := range endings {
+		stem, ok := strings.CutSuffix(name, ending)
+		if ok && utf8.RuneCountInString(stem) >= 2 {
+			return stem
+		}
+	}
+	return name
+}
+
+// Phonetic возвращает упрощённый фонетический ключ основы имени:
+// согласные оглушаются, безударное «о» совпадает с «а», а повторы букв схлопываются.
+func Phonetic(name string) string {
+	key := voiced.Replace(Stem(name))
+	key = strings.ReplaceAll(key, "о", "а")
+
+	var b strings.Builder
+	var prev rune
+	for _, r := range key {
+		if r != prev {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	return b.String()
+}
+
+// Distance возвращает расстояние Левенштейна между строками в рунах.
+func Distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cur := row[j]
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			row[j] = min(row[j]+1, row[j-1]+1, prev+cost)
+			prev = cur
+		}
+	}
+	return row[len(rb)]
+}
+
+// score оценивает близость кандидата к запросу: чем меньше, тем лучше.
+// Совпадение основ оценивается нулём, фонетическая близость
+// ценится выше совпадения букв.
+func score(query, candidate string) int {
+	if Stem(query) == Stem(candidate) {
+		return 0
+	}
+	return min(Distance(Phonetic(query), Phonetic(candidate))*2, Distance(Stem(query), Stem(candidate))*2+1)
+}
+
+// Match возвращает кандидатов, наиболее близких к одному из вариантов имени queries,
+// например к распознанной фразе и к имени из сущности YANDEX.FIO.
+// Если кандидатов с лучшей оценкой несколько, возвращаются все они
+// в алфавитном порядке; если подходящих нет, возвращается nil.
+func Match(queries []string, candidates []string) []string {
+	best := -1
+	var matches []string
+	for _, candidate := range candidates {
+		s := -1
+		for _, query := range queries {
+			if query == "" {
+				continue
+			}
+			if qs := score(query, candidate); s < 0 || qs < s {
+				s = qs
+			}
+		}
+		if s < 0 || s > threshold(candidate) {
+			continue
+		}
+
+		switch {
+		case best < 0 || s < best:
+			best = s
+			matches = []string{candidate}
+		case s == best:
+			matches = append(matches, candidate)
+		}
+	}
+
+	sort.Strings(matches)
+	return matches
+}
+
+// threshold возвращает максимально допустимую оценку для кандидата:
+// чем длиннее имя, тем больше ошибок распознавания допускается.
+func threshold(candidate string) int {
+	return max(2, utf8.RuneCountInString(Stem(candidate))*2/3)
+}
+
+// alternates задаёт буквы, которые распознавание речи часто путает
+// в начале имени.
+var alternates = map[rune][]rune{
+	'а': {'о'}, 'о': {'а'},
+	'е': {'и', 'э'}, 'и': {'е'}, 'э': {'е'},
+}
+
+// Initials возвращает первые буквы вариантов имени вместе с буквами,
+// которые с ними часто путают. По ним хранилище отбирает кандидатов для Match.
+func Initials(queries []string) []string {
+	seen := make(map[rune]bool)
+	var initials []string
+	add := func(r rune) {
+		if !seen[r] {
+			seen[r] = true
+			initials = append(initials, string(r))
+		}
+	}
+
+	for _, query := range queries {
+		r, _ := utf8.DecodeRuneInString(Normalize(query))
+		if r == utf8.RuneError {
+			continue
+		}
+		add(r)
+		for _, alt := range alternates[r] {
+			add(alt)
+		}
+	}
+	return initials
+}
+
+// Genitive ставит имя в родительный падеж для фраз вида «от Ивана».
+// Склоняются только имена на кириллице с типичными окончаниями,
+// остальные возвращаются без изменений.
+func Genitive(name string) string {
+	runes := []rune(name)
+	if len(runes) < 2 || !unicode.Is(unicode.Cyrillic, runes[len(runes)-1]) {
+		return name
+	}
+
+	last := unicode.ToLower(runes[len(runes)-1])
+	prev := unicode.ToLower(runes[len(runes)-2])
+	stem := string(runes[:len(runes)-1])
+
+	switch {
+	case last == 'а' && strings.ContainsRune("гкхжчшщ", prev), last == 'я':
+		return stem + matchCase("и", runes[len(runes)-1])
+	case last == 'а':
+		return stem + matchCase("ы", runes[len(runes)-1])
+	case last == 'й', last == 'ь':
+		return stem + matchCase("я", runes[len(runes)-1])
+	case strings.ContainsRune("бвгджзклмнпрстфхцчшщ", last):
+		return name + matchCase("а", runes[len(runes)-1])
+	}
+	return name
+}
+
+// matchCase приводит окончание к регистру последней буквы имени.
+func matchCase(ending string, last rune) string {
+	if unicode.IsUpper(last) {
+		return strings.ToUpper(ending)
+	}
+	return ending
+}
@@ -0,0 +1,80 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStem(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "Ивану", expected: "иван"},
+		{name: "Иван", expected: "иван"},
+		{name: "Маше", expected: "маш"},
+		{name: "Маша", expected: "маш"},
+		{name: "Алёне", expected: "ален"},
+		{name: "Ия", expected: "ия"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Stem(tc.name))
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	assert.Equal(t, 0, Distance("иван", "иван"))
+	assert.Equal(t, 1, Distance("иван", "ивон"))
+	assert.Equal(t, 3, Distance("", "маш"))
+}
+
+func TestMatch(t *testing.T) {
+	candidates := []string{"Иван", "Ивана", "Маша", "Олег"}
+
+	testCases := []struct {
+		name     string
+		queries  []string
+		expected []string
+	}{
+		{name: "dative", queries: []string{"маше"}, expected: []string{"Маша"}},
+		{name: "ambiguous", queries: []string{"ивану"}, expected: []string{"Иван", "Ивана"}},
+		{name: "misspelled", queries: []string{"алегу"}, expected: []string{"Олег"}},
+		{name: "fio", queries: []string{"олежке", "олег"}, expected: []string{"Олег"}},
+		{name: "unknown", queries: []string{"пётр"}, expected: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Match(tc.queries, candidates))
+		})
+	}
+}
+
+func TestInitials(t *testing.T) {
+	assert.Equal(t, []string{"а", "о", "м"}, Initials([]string{"Алегу", "маше", "Олег", ""}))
+}
+
+func TestGenitive(t *testing.T) {
+	testCases := map[string]string{
+		"Иван":   "Ивана",
+		"Маша":   "Маши",
+		"Ольга":  "Ольги",
+		"Анна":   "Анны",
+		"Настя":  "Насти",
+		"Андрей": "Андрея",
+		"Игорь":  "Игоря",
+		"ОЛЕГ":   "ОЛЕГА",
+		"Ivan":   "Ivan",
+		"Лео":    "Лео",
+	}
+
+	for name, expected := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, expected, Genitive(name))
+		})
+	}
+}
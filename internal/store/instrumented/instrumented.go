@@ -0,0 +1,330 @@
+// Package instrumented оборачивает store.Store, чтобы измерять
+// и трассировать каждое обращение к хранилищу.
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/store"
+)
+
+// Hook вызывается перед обращением к методу хранилища method. Возвращённый
+// контекст передаётся хранилищу, а done вызывается с результатом обращения.
+type Hook func(ctx context.Context, method string) (_ context.Context, done func(err error))
+
+// Store вызывает Hook вокруг каждого метода обёрнутого хранилища.
+type Store struct {
+	store store.Store
+	hook  Hook
+}
+
+var _ store.Store = (*Store)(nil)
+
+// New оборачивает хранилище s.
+func New(s store.Store, hook Hook) *Store {
+	return &Store{store: s, hook: hook}
+}
+
+// Chain объединяет несколько хуков: первый оборачивает остальные,
+// а done вызываются в обратном порядке.
+func Chain(hooks ...Hook) Hook {
+	return func(ctx context.Context, method string) (context.Context, func(err error)) {
+		dones := make([]func(err error), len(hooks))
+		for i, hook := range hooks {
+			ctx, dones[i] = hook(ctx, method)
+		}
+		return ctx, func(err error) {
+			for i := len(dones) - 1; i >= 0; i-- {
+				dones[i](err)
+			}
+		}
+	}
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	ctx, done := s.hook(ctx, "Ping")
+	err := s.store.Ping(ctx)
+	done(err)
+	return err
+}
+
+func (s *Store) FindRecepient(ctx context.Context, username string) (string, error) {
+	ctx, done := s.hook(ctx, "FindRecepient")
+	result, err := s.store.FindRecepient(ctx, username)
+	done(err)
+	return result, err
+}
+
+func (s *Store) FindUsernames(ctx context.Context, initials []string) ([]string, error) {
+	ctx, done := s.hook(ctx, "FindUsernames")
+	result, err := s.store.FindUsernames(ctx, initials)
+	done(err)
+	return result, err
+}
+
+func (s *Store) ListMessages(ctx context.Context, userID string) ([]store.Message, error) {
+	ctx, done := s.hook(ctx, "ListMessages")
+	result, err := s.store.ListMessages(ctx, userID)
+	done(err)
+	return result, err
+}
+
+func (s *Store) GetMessage(ctx context.Context, id int64) (*store.Message, error) {
+	ctx, done := s.hook(ctx, "GetMessage")
+	result, err := s.store.GetMessage(ctx, id)
+	done(err)
+	return result, err
+}
+
+func (s *Store) SaveMessages(ctx context.Context, messages ...store.Message) error {
+	ctx, done := s.hook(ctx, "SaveMessages")
+	err := s.store.SaveMessages(ctx, messages...)
+	done(err)
+	return err
+}
+
+func (s *Store) SummarizeUnread(ctx context.Context, userID string) ([]store.SenderSummary, error) {
+	ctx, done := s.hook(ctx, "SummarizeUnread")
+	result, err := s.store.SummarizeUnread(ctx, userID)
+	done(err)
+	return result, err
+}
+
+func (s *Store) ListUnread(ctx context.Context, userID string, limit int) ([]store.Message, error) {
+	ctx, done := s.hook(ctx, "ListUnread")
+	result, err := s.store.ListUnread(ctx, userID, limit)
+	done(err)
+	return result, err
+}
+
+func (s *Store) ListUnreadFrom(ctx context.Context, userID, senderID string) ([]store.Message, error) {
+	ctx, done := s.hook(ctx, "ListUnreadFrom")
+	result, err := s.store.ListUnreadFrom(ctx, userID, senderID)
+	done(err)
+	return result, err
+}
+
+func (s *Store) MarkRead(ctx context.Context, id int64) error {
+	ctx, done := s.hook(ctx, "MarkRead")
+	err := s.store.MarkRead(ctx, id)
+	done(err)
+	return err
+}
+
+func (s *Store) SearchMessages(ctx context.Context, userID string, query store.SearchQuery) ([]store.Message, error) {
+	ctx, done := s.hook(ctx, "SearchMessages")
+	result, err := s.store.SearchMessages(ctx, userID, query)
+	done(err)
+	return result, err
+}
+
+func (s *Store) GetReceipt(ctx context.Context, senderID, recepientID string) (*store.Message, error) {
+	ctx, done := s.hook(ctx, "GetReceipt")
+	result, err := s.store.GetReceipt(ctx, senderID, recepientID)
+	done(err)
+	return result, err
+}
+
+func (s *Store) TakeReadReceipts(ctx context.Context, senderID string) ([]store.Message, error) {
+	ctx, done := s.hook(ctx, "TakeReadReceipts")
+	result, err := s.store.TakeReadReceipts(ctx, senderID)
+	done(err)
+	return result, err
+}
+
+func (s *Store) SetSendReceipts(ctx context.Context, userID string, enabled bool) error {
+	ctx, done := s.hook(ctx, "SetSendReceipts")
+	err := s.store.SetSendReceipts(ctx, userID, enabled)
+	done(err)
+	return err
+}
+
+func (s *Store) ReportMessage(ctx context.Context, reporterID string, id int64) error {
+	ctx, done := s.hook(ctx, "ReportMessage")
+	err := s.store.ReportMessage(ctx, reporterID, id)
+	done(err)
+	return err
+}
+
+func (s *Store) ListScheduled(ctx context.Context, senderID string) ([]store.Message, error) {
+	ctx, done := s.hook(ctx, "ListScheduled")
+	result, err := s.store.ListScheduled(ctx, senderID)
+	done(err)
+	return result, err
+}
+
+func (s *Store) CancelScheduled(ctx context.Context, senderID string, id int64) error {
+	ctx, done := s.hook(ctx, "CancelScheduled")
+	err := s.store.CancelScheduled(ctx, senderID, id)
+	done(err)
+	return err
+}
+
+func (s *Store) ListReminders(ctx context.Context, userID string) ([]store.Message, error) {
+	ctx, done := s.hook(ctx, "ListReminders")
+	result, err := s.store.ListReminders(ctx, userID)
+	done(err)
+	return result, err
+}
+
+func (s *Store) ListDueReminders(ctx context.Context, userID string) ([]store.Message, error) {
+	ctx, done := s.hook(ctx, "ListDueReminders")
+	result, err := s.store.ListDueReminders(ctx, userID)
+	done(err)
+	return result, err
+}
+
+func (s *Store) CompleteReminder(ctx context.Context, id int64, next time.Time) error {
+	ctx, done := s.hook(ctx, "CompleteReminder")
+	err := s.store.CompleteReminder(ctx, id, next)
+	done(err)
+	return err
+}
+
+func (s *Store) DeleteReminder(ctx context.Context, userID string, id int64) error {
+	ctx, done := s.hook(ctx, "DeleteReminder")
+	err := s.store.DeleteReminder(ctx, userID, id)
+	done(err)
+	return err
+}
+
+func (s *Store) PurgeExpired(ctx context.Context, policy store.RetentionPolicy, limit int) (int64, error) {
+	ctx, done := s.hook(ctx, "PurgeExpired")
+	result, err := s.store.PurgeExpired(ctx, policy, limit)
+	done(err)
+	return result, err
+}
+
+func (s *Store) RegisterUser(ctx context.Context, userID, username string) error {
+	ctx, done := s.hook(ctx, "RegisterUser")
+	err := s.store.RegisterUser(ctx, userID, username)
+	done(err)
+	return err
+}
+
+func (s *Store) GetUser(ctx context.Context, userID string) (*store.User, error) {
+	ctx, done := s.hook(ctx, "GetUser")
+	result, err := s.store.GetUser(ctx, userID)
+	done(err)
+	return result, err
+}
+
+func (s *Store) RenameUser(ctx context.Context, userID, username string) error {
+	ctx, done := s.hook(ctx, "RenameUser")
+	err := s.store.RenameUser(ctx, userID, username)
+	done(err)
+	return err
+}
+
+func (s *Store) DeleteUser(ctx context.Context, userID string) error {
+	ctx, done := s.hook(ctx, "DeleteUser")
+	err := s.store.DeleteUser(ctx, userID)
+	done(err)
+	return err
+}
+
+func (s *Store) MigrateUser(ctx context.Context, fromID, toID string) error {
+	ctx, done := s.hook(ctx, "MigrateUser")
+	err := s.store.MigrateUser(ctx, fromID, toID)
+	done(err)
+	return err
+}
+
+func (s *Store) SaveToken(ctx context.Context, tokenHash, account string) error {
+	ctx, done := s.hook(ctx, "SaveToken")
+	err := s.store.SaveToken(ctx, tokenHash, account)
+	done(err)
+	return err
+}
+
+func (s *Store) FindTokenAccount(ctx context.Context, tokenHash string) (string, error) {
+	ctx, done := s.hook(ctx, "FindTokenAccount")
+	result, err := s.store.FindTokenAccount(ctx, tokenHash)
+	done(err)
+	return result, err
+}
+
+func (s *Store) CreateGroup(ctx context.Context, ownerID, name string) error {
+	ctx, done := s.hook(ctx, "CreateGroup")
+	err := s.store.CreateGroup(ctx, ownerID, name)
+	done(err)
+	return err
+}
+
+func (s *Store) AddGroupMember(ctx context.Context, ownerID, name, memberID string) error {
+	ctx, done := s.hook(ctx, "AddGroupMember")
+	err := s.store.AddGroupMember(ctx, ownerID, name, memberID)
+	done(err)
+	return err
+}
+
+func (s *Store) RemoveGroupMember(ctx context.Context, ownerID, name, memberID string) error {
+	ctx, done := s.hook(ctx, "RemoveGroupMember")
+	err := s.store.RemoveGroupMember(ctx, ownerID, name, memberID)
+	done(err)
+	return err
+}
+
+func (s *Store) ListGroupMembers(ctx context.Context, ownerID, name string) ([]string, error) {
+	ctx, done := s.hook(ctx, "ListGroupMembers")
+	result, err := s.store.ListGroupMembers(ctx, ownerID, name)
+	done(err)
+	return result, err
+}
+
+func (s *Store) ListGroups(ctx context.Context, ownerID string) ([]string, error) {
+	ctx, done := s.hook(ctx, "ListGroups")
+	result, err := s.store.ListGroups(ctx, ownerID)
+	done(err)
+	return result, err
+}
+
+func (s *Store) SaveContact(ctx context.Context, ownerID, alias, contactID string) error {
+	ctx, done := s.hook(ctx, "SaveContact")
+	err := s.store.SaveContact(ctx, ownerID, alias, contactID)
+	done(err)
+	return err
+}
+
+func (s *Store) RemoveContact(ctx context.Context, ownerID, alias string) error {
+	ctx, done := s.hook(ctx, "RemoveContact")
+	err := s.store.RemoveContact(ctx, ownerID, alias)
+	done(err)
+	return err
+}
+
+func (s *Store) FindContact(ctx context.Context, ownerID, alias string) (string, error) {
+	ctx, done := s.hook(ctx, "FindContact")
+	result, err := s.store.FindContact(ctx, ownerID, alias)
+	done(err)
+	return result, err
+}
+
+func (s *Store) ListContacts(ctx context.Context, ownerID string) (map[string]string, error) {
+	ctx, done := s.hook(ctx, "ListContacts")
+	result, err := s.store.ListContacts(ctx, ownerID)
+	done(err)
+	return result, err
+}
+
+func (s *Store) BlockUser(ctx context.Context, ownerID, blockedID string) error {
+	ctx, done := s.hook(ctx, "BlockUser")
+	err := s.store.BlockUser(ctx, ownerID, blockedID)
+	done(err)
+	return err
+}
+
+func (s *Store) UnblockUser(ctx context.Context, ownerID, blockedID string) error {
+	ctx, done := s.hook(ctx, "UnblockUser")
+	err := s.store.UnblockUser(ctx, ownerID, blockedID)
+	done(err)
+	return err
+}
+
+func (s *Store) SetContactsOnly(ctx context.Context, userID string, enabled bool) error {
+	ctx, done := s.hook(ctx, "SetContactsOnly")
+	err := s.store.SetContactsOnly(ctx, userID, enabled)
+	done(err)
+	return err
+}
@@ -0,0 +1,46 @@
+package instrumented
+
+import (
+	"context"
+	"testing"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/store"
+	"github.com/VladimirAzanza/alisa_skill/mocks"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+type ctxKey struct{}
+
+func TestStore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	m := mocks.NewMockStore(ctrl)
+
+	var calls []string
+	hook := func(name string) Hook {
+		return func(ctx context.Context, method string) (context.Context, func(err error)) {
+			calls = append(calls, name+" start "+method)
+			return context.WithValue(ctx, ctxKey{}, name), func(err error) {
+				calls = append(calls, name+" done "+method+" "+err.Error())
+			}
+		}
+	}
+
+	m.EXPECT().
+		FindRecepient(gomock.Any(), "ivan").
+		DoAndReturn(func(ctx context.Context, username string) (string, error) {
+			// хранилище получает контекст, возвращённый последним хуком
+			assert.Equal(t, "inner", ctx.Value(ctxKey{}))
+			return "", store.ErrNotFound
+		})
+
+	s := New(m, Chain(hook("outer"), hook("inner")))
+	_, err := s.FindRecepient(context.Background(), "ivan")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	assert.Equal(t, []string{
+		"outer start FindRecepient",
+		"inner start FindRecepient",
+		"inner done FindRecepient " + store.ErrNotFound.Error(),
+		"outer done FindRecepient " + store.ErrNotFound.Error(),
+	}, calls)
+}
@@ -13,6 +13,17 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// visibleMessage отбирает сообщения m, которые получатель может видеть:
+// доставленные, не истёкшие, не напоминания и не от заблокированных отправителей.
+const visibleMessage = `
+    NOT EXISTS (
+        SELECT 1 FROM blocks b WHERE b.owner = m.recepient AND b.blocked = m.sender
+    )
+    AND (m.deliver_at IS NULL OR m.deliver_at <= now())
+    AND (m.expires_at IS NULL OR m.expires_at > now())
+    AND NOT m.reminder
+`
+
 type Store struct {
 	conn *sql.DB
 }
@@ -21,6 +32,11 @@ func NewStore(conn *sql.DB) *Store {
 	return &Store{conn: conn}
 }
 
+// Ping проверяет, что база данных доступна.
+func (s Store) Ping(ctx context.Context) error {
+	return s.conn.PingContext(ctx)
+}
+
 func (s Store) RegisterUser(ctx context.Context, userID, username string) error {
 	_, err := s.conn.ExecContext(ctx, `
         INSERT INTO users
@@ -39,7 +55,44 @@ func (s Store) RegisterUser(ctx context.Context, userID, username string) error
 	return err
 }
 
-func (s Store) Bootstrap(ctx context.Context) error {
+func (s Store) GetUser(ctx context.Context, userID string) (*store.User, error) {
+	row := s.conn.QueryRowContext(ctx, `SELECT id, username FROM users WHERE id = $1`, userID)
+
+	var user store.User
+	err := row.Scan(&user.ID, &user.Username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s Store) RenameUser(ctx context.Context, userID, username string) error {
+	res, err := s.conn.ExecContext(ctx, `UPDATE users SET username = $2 WHERE id = $1`, userID, username)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			err = store.ErrConflict
+		}
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteUser удаляет пользователя вместе с полученными им сообщениями,
+// в том числе архивными, группами, контактами и блокировками. Отправленные
+// им сообщения остаются у получателей, но отвязываются от удалённого аккаунта.
+func (s Store) DeleteUser(ctx context.Context, userID string) error {
 	tx, err := s.conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
@@ -47,45 +100,283 @@ func (s Store) Bootstrap(ctx context.Context) error {
 
 	defer tx.Rollback()
 
-	tx.ExecContext(ctx, `
-        CREATE TABLE users (
+	queries := []string{
+		`DELETE FROM messages WHERE recepient = $1`,
+		`UPDATE messages SET sender = NULL WHERE sender = $1`,
+		`DELETE FROM messages_archive WHERE recepient = $1`,
+		`UPDATE messages_archive SET sender = NULL WHERE sender = $1`,
+		`DELETE FROM groups WHERE owner = $1`,
+		`DELETE FROM group_members WHERE member = $1`,
+		`DELETE FROM contacts WHERE owner = $1 OR contact = $1`,
+		`DELETE FROM blocks WHERE owner = $1 OR blocked = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return store.ErrNotFound
+	}
+
+	return tx.Commit()
+}
+
+// MigrateUser переносит аккаунт fromID со всеми сообщениями, группами,
+// контактами и блокировками на идентификатор toID. Если у toID уже есть
+// аккаунт, возвращается store.ErrConflict.
+func (s Store) MigrateUser(ctx context.Context, fromID, toID string) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE users SET id = $2 WHERE id = $1`, fromID, toID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			err = store.ErrConflict
+		}
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return store.ErrNotFound
+	}
+
+	queries := []string{
+		`UPDATE messages SET recepient = $2 WHERE recepient = $1`,
+		`UPDATE messages SET sender = $2 WHERE sender = $1`,
+		`UPDATE messages_archive SET recepient = $2 WHERE recepient = $1`,
+		`UPDATE messages_archive SET sender = $2 WHERE sender = $1`,
+		`UPDATE groups SET owner = $2 WHERE owner = $1`,
+		`UPDATE group_members SET member = $2 WHERE member = $1`,
+		`UPDATE contacts SET owner = $2 WHERE owner = $1`,
+		`UPDATE contacts SET contact = $2 WHERE contact = $1`,
+		`UPDATE blocks SET owner = $2 WHERE owner = $1`,
+		`UPDATE blocks SET blocked = $2 WHERE blocked = $1`,
+		`UPDATE reports SET reporter = $2 WHERE reporter = $1`,
+		`UPDATE reports SET sender = $2 WHERE sender = $1`,
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, fromID, toID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SaveToken сохраняет хеш выданного токена доступа и аккаунт каталога, к которому он относится.
+func (s Store) SaveToken(ctx context.Context, tokenHash, account string) error {
+	_, err := s.conn.ExecContext(ctx, `INSERT INTO tokens (token_hash, account) VALUES ($1, $2)`, tokenHash, account)
+	return err
+}
+
+// FindTokenAccount возвращает аккаунт каталога по хешу токена доступа.
+func (s Store) FindTokenAccount(ctx context.Context, tokenHash string) (account string, err error) {
+	row := s.conn.QueryRowContext(ctx, `SELECT account FROM tokens WHERE token_hash = $1`, tokenHash)
+	err = row.Scan(&account)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = store.ErrNotFound
+	}
+	return
+}
+
+// messageColumns перечисляет столбцы сообщений, добавленные после первой версии
+// схемы. Они есть и в messages, и в messages_archive.
+var messageColumns = []string{
+	`reply_to integer DEFAULT NULL`,
+	`group_name varchar(128) DEFAULT NULL`,
+	`deliver_at timestamp with time zone DEFAULT NULL`,
+	`reminder boolean DEFAULT false`,
+	`recurrence varchar(16) DEFAULT NULL`,
+	`expires_at timestamp with time zone DEFAULT NULL`,
+	`receipt_announced boolean DEFAULT false`,
+}
+
+// archiveColumns — столбцы, которые переносятся в messages_archive.
+// Список задан явно: в обновлённых базах порядок столбцов двух таблиц может различаться.
+const archiveColumns = `id, sender, recepient, payload, sent_at, read_at, reply_to, group_name, deliver_at, reminder, recurrence, expires_at, receipt_announced`
+
+// migrations приводят схему к текущей версии. Каждая инструкция идемпотентна,
+// поэтому они выполняются при каждом запуске и на новой, и на существующей базе.
+func migrations() []string {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS users (
             id varchar(128) PRIMARY KEY,
             username varchar(128)
-        )
-    `)
-	tx.ExecContext(ctx, `CREATE UNIQUE INDEX sender_idx ON users (username)`)
+        )`,
+		`ALTER TABLE users
+            ADD COLUMN IF NOT EXISTS contacts_only boolean DEFAULT false,
+            ADD COLUMN IF NOT EXISTS send_receipts boolean DEFAULT true,
+            ADD COLUMN IF NOT EXISTS flagged boolean DEFAULT false`,
+		// имена, различающиеся только регистром, «ё»/«е» или формой Unicode, считаются одинаковыми;
+		// индекс первой версии схемы сравнивал имена точно
+		`DROP INDEX IF EXISTS sender_idx`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS username_normalized_idx ON users (lower(translate(normalize(username, NFC), 'ёЁ', 'еЕ')))`,
 
-	tx.ExecContext(ctx, `
-        CREATE TABLE messages (
+		`CREATE TABLE IF NOT EXISTS messages (
             id serial PRIMARY KEY,
             sender varchar(128),
             recepient varchar(128),
             payload text,
             sent_at timestamp with time zone,
             read_at timestamp with time zone DEFAULT NULL
-        )
-    `)
-	tx.ExecContext(ctx, `CREATE INDEX recepient_idx ON messages (recepient)`)
+        )`,
+		`CREATE TABLE IF NOT EXISTS messages_archive (LIKE messages INCLUDING DEFAULTS)`,
+	}
+	for _, table := range []string{"messages", "messages_archive"} {
+		for _, column := range messageColumns {
+			queries = append(queries, `ALTER TABLE `+table+` ADD COLUMN IF NOT EXISTS `+column)
+		}
+	}
+
+	return append(queries,
+		`DO $$ BEGIN
+            ALTER TABLE messages ADD CONSTRAINT messages_reply_to_fkey
+                FOREIGN KEY (reply_to) REFERENCES messages (id) ON DELETE SET NULL;
+        EXCEPTION WHEN duplicate_object THEN NULL;
+        END $$`,
+		`CREATE INDEX IF NOT EXISTS recepient_idx ON messages (recepient)`,
+		`CREATE INDEX IF NOT EXISTS expires_at_idx ON messages (expires_at) WHERE expires_at IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS payload_fts_idx ON messages USING gin (to_tsvector('russian', payload))`,
+
+		`CREATE TABLE IF NOT EXISTS groups (
+            id serial PRIMARY KEY,
+            owner varchar(128),
+            name varchar(128)
+        )`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS group_owner_name_idx ON groups (owner, name)`,
+		`CREATE TABLE IF NOT EXISTS group_members (
+            group_id integer REFERENCES groups (id) ON DELETE CASCADE,
+            member varchar(128),
+            PRIMARY KEY (group_id, member)
+        )`,
+
+		`CREATE TABLE IF NOT EXISTS contacts (
+            owner varchar(128),
+            alias varchar(128),
+            contact varchar(128),
+            PRIMARY KEY (owner, alias)
+        )`,
+		`CREATE TABLE IF NOT EXISTS blocks (
+            owner varchar(128),
+            blocked varchar(128),
+            PRIMARY KEY (owner, blocked)
+        )`,
+
+		`CREATE TABLE IF NOT EXISTS reports (
+            message_id integer REFERENCES messages (id) ON DELETE SET NULL,
+            reporter varchar(128),
+            sender varchar(128),
+            payload text,
+            reported_at timestamp with time zone DEFAULT now()
+        )`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS report_message_reporter_idx ON reports (message_id, reporter)`,
+
+		`CREATE TABLE IF NOT EXISTS tokens (
+            token_hash varchar(64) PRIMARY KEY,
+            account varchar(128),
+            issued_at timestamp with time zone DEFAULT now()
+        )`,
+	)
+}
+
+// bootstrapLockID — ключ advisory-блокировки, под которой выполняются миграции,
+// чтобы одновременно запущенные экземпляры не мешали друг другу.
+const bootstrapLockID = 73182604
+
+// Bootstrap создаёт недостающие таблицы, столбцы и индексы. Миграции выполняются
+// в одной транзакции: при ошибке схема остаётся прежней.
+func (s Store) Bootstrap(ctx context.Context) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, bootstrapLockID); err != nil {
+		return err
+	}
+	for i, query := range migrations() {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("migration %d: %w", i, err)
+		}
+	}
 
 	return tx.Commit()
 }
 
 func (s Store) FindRecepient(ctx context.Context, username string) (userID string, err error) {
-	row := s.conn.QueryRowContext(ctx, `SELECT id FROM users WHERE username = $1`, username)
+	row := s.conn.QueryRowContext(ctx, `
+        SELECT id
+        FROM users
+        WHERE
+            lower(translate(normalize(username, NFC), 'ёЁ', 'еЕ')) = lower(translate(normalize($1, NFC), 'ёЁ', 'еЕ'))
+    `, username)
 	err = row.Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = store.ErrNotFound
+	}
 	return
 }
 
+func (s Store) FindUsernames(ctx context.Context, initials []string) ([]string, error) {
+	rows, err := s.conn.QueryContext(ctx, `
+        SELECT username
+        FROM users
+        WHERE
+            lower(left(username, 1)) = ANY($1)
+    `, initials)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var usernames []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		usernames = append(usernames, username)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return usernames, nil
+}
+
 func (s Store) ListMessages(ctx context.Context, userID string) ([]store.Message, error) {
 	rows, err := s.conn.QueryContext(ctx, `
         SELECT
             m.id,
-            u.username AS sender,
-            m.sent_at
+            COALESCE(u.username, '') AS sender,
+            m.sent_at,
+            m.group_name
         FROM messages m
-        JOIN users u ON m.sender = u.id
+        LEFT JOIN users u ON m.sender = u.id
         WHERE
             m.recepient = $1
+            AND `+visibleMessage+`
     `, userID)
 	if err != nil {
 		return nil, err
@@ -96,9 +387,11 @@ func (s Store) ListMessages(ctx context.Context, userID string) ([]store.Message
 	var messages []store.Message
 	for rows.Next() {
 		var m store.Message
-		if err := rows.Scan(&m.ID, &m.Sender, &m.Time); err != nil {
+		var group sql.NullString
+		if err := rows.Scan(&m.ID, &m.Sender, &m.Time, &group); err != nil {
 			return nil, err
 		}
+		m.Group = group.String
 		messages = append(messages, m)
 	}
 	if err := rows.Err(); err != nil {
@@ -112,11 +405,13 @@ func (s Store) GetMessage(ctx context.Context, id int64) (*store.Message, error)
 	row := s.conn.QueryRowContext(ctx, `
         SELECT
             m.id,
-            u.username AS sender,
+            COALESCE(u.username, '') AS sender,
             m.payload,
-            m.sent_at
+            m.sent_at,
+            m.reply_to,
+            m.group_name
         FROM messages m
-        JOIN users u ON m.sender = u.id
+        LEFT JOIN users u ON m.sender = u.id
         WHERE
             m.id = $1
     `,
@@ -124,10 +419,17 @@ func (s Store) GetMessage(ctx context.Context, id int64) (*store.Message, error)
 	)
 
 	var msg store.Message
-	err := row.Scan(&msg.ID, &msg.Sender, &msg.Payload, &msg.Time)
+	var replyTo sql.NullInt64
+	var group sql.NullString
+	err := row.Scan(&msg.ID, &msg.Sender, &msg.Payload, &msg.Time, &replyTo, &group)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
+	msg.ReplyTo = replyTo.Int64
+	msg.Group = group.String
 	return &msg, nil
 }
 
@@ -146,18 +448,711 @@ func (s Store) SaveMessages(ctx context.Context, messages ...store.Message) erro
 	var values []string
 	var args []any
 	for i, msg := range messages {
-		base := i * 4
-		params := fmt.Sprintf("($%d, $%d, $%d, $%d)", base+1, base+2, base+3, base+4)
+		base := i * 10
+		params := fmt.Sprintf(
+			"($%d, $%d, $%d, $%d::timestamptz, $%d::integer, $%d, $%d::timestamptz, $%d::boolean, $%d, $%d::timestamptz)",
+			base+1, base+2, base+3, base+4, base+5, base+6, base+7, base+8, base+9, base+10,
+		)
 		values = append(values, params)
-		args = append(args, msg.Sender, msg.Recepient, msg.Payload, msg.Time)
+
+		var replyTo sql.NullInt64
+		if msg.ReplyTo != 0 {
+			replyTo = sql.NullInt64{Int64: msg.ReplyTo, Valid: true}
+		}
+		group := sql.NullString{String: msg.Group, Valid: msg.Group != ""}
+		deliverAt := sql.NullTime{Time: msg.DeliverAt, Valid: !msg.DeliverAt.IsZero()}
+		recurrence := sql.NullString{String: msg.Recurrence, Valid: msg.Recurrence != ""}
+		expiresAt := sql.NullTime{Time: msg.ExpiresAt, Valid: !msg.ExpiresAt.IsZero()}
+		args = append(args, msg.Sender, msg.Recepient, msg.Payload, msg.Time, replyTo, group, deliverAt, msg.Reminder, recurrence, expiresAt)
 	}
 
+	// сообщения от заблокированных отправителей, а также от отправителей
+	// не из списка контактов получателя, принимающего сообщения только от контактов,
+	// молча отбрасываются; сообщения самому себе, например напоминания, принимаются всегда.
+	// Исходное сообщение могли удалить, пока ответ ждал записи: тогда ответ
+	// сохраняется без ссылки, иначе внешний ключ не дал бы записать весь пакет
 	query := `
   INSERT INTO messages
-  (sender, recepient, payload, sent_at)
-  VALUES ` + strings.Join(values, ",") + `;`
+  (sender, recepient, payload, sent_at, reply_to, group_name, deliver_at, reminder, recurrence, expires_at)
+  SELECT v.sender, v.recepient, v.payload, v.sent_at, (SELECT m.id FROM messages m WHERE m.id = v.reply_to), v.group_name, v.deliver_at, v.reminder, v.recurrence, v.expires_at
+  FROM (VALUES ` + strings.Join(values, ",") + `) AS v (sender, recepient, payload, sent_at, reply_to, group_name, deliver_at, reminder, recurrence, expires_at)
+  WHERE
+    NOT EXISTS (
+      SELECT 1 FROM blocks b WHERE b.owner = v.recepient AND b.blocked = v.sender
+    )
+    AND NOT EXISTS (
+      SELECT 1 FROM users u
+      WHERE
+        u.id = v.recepient
+        AND u.id <> v.sender
+        AND u.contacts_only
+        AND NOT EXISTS (SELECT 1 FROM contacts c WHERE c.owner = v.recepient AND c.contact = v.sender)
+    );`
 
 	_, err := s.conn.ExecContext(ctx, query, args...)
 
 	return err
 }
+
+// SummarizeUnread подсчитывает непрочитанные сообщения по отправителям,
+// отправители с самыми свежими сообщениями идут первыми.
+func (s Store) SummarizeUnread(ctx context.Context, userID string) ([]store.SenderSummary, error) {
+	rows, err := s.conn.QueryContext(ctx, `
+        SELECT
+            COALESCE(m.sender, ''),
+            COALESCE(u.username, ''),
+            count(*),
+            max(COALESCE(m.deliver_at, m.sent_at)) AS latest
+        FROM messages m
+        LEFT JOIN users u ON m.sender = u.id
+        WHERE
+            m.recepient = $1
+            AND m.read_at IS NULL
+            AND `+visibleMessage+`
+        GROUP BY m.sender, u.username
+        ORDER BY latest DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var summaries []store.SenderSummary
+	for rows.Next() {
+		var summary store.SenderSummary
+		if err := rows.Scan(&summary.SenderID, &summary.Sender, &summary.Count, &summary.Latest); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+// ListUnread возвращает не больше limit непрочитанных сообщений в порядке отправки.
+func (s Store) ListUnread(ctx context.Context, userID string, limit int) ([]store.Message, error) {
+	return s.queryUnread(ctx, `
+        SELECT
+            m.id,
+            COALESCE(u.username, '') AS sender,
+            m.payload,
+            m.sent_at,
+            m.group_name
+        FROM messages m
+        LEFT JOIN users u ON m.sender = u.id
+        WHERE
+            m.recepient = $1
+            AND m.read_at IS NULL
+            AND `+visibleMessage+`
+        ORDER BY m.sent_at, m.id
+        LIMIT $2
+    `, userID, limit)
+}
+
+// ListUnreadFrom возвращает непрочитанные сообщения одного отправителя
+// в порядке отправки.
+func (s Store) ListUnreadFrom(ctx context.Context, userID, senderID string) ([]store.Message, error) {
+	return s.queryUnread(ctx, `
+        SELECT
+            m.id,
+            COALESCE(u.username, '') AS sender,
+            m.payload,
+            m.sent_at,
+            m.group_name
+        FROM messages m
+        LEFT JOIN users u ON m.sender = u.id
+        WHERE
+            m.recepient = $1
+            AND m.sender = $2
+            AND m.read_at IS NULL
+            AND `+visibleMessage+`
+        ORDER BY m.sent_at, m.id
+    `, userID, senderID)
+}
+
+func (s Store) queryUnread(ctx context.Context, query string, args ...any) ([]store.Message, error) {
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var messages []store.Message
+	for rows.Next() {
+		var m store.Message
+		var group sql.NullString
+		if err := rows.Scan(&m.ID, &m.Sender, &m.Payload, &m.Time, &group); err != nil {
+			return nil, err
+		}
+		m.Group = group.String
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (s Store) MarkRead(ctx context.Context, id int64) error {
+	_, err := s.conn.ExecContext(ctx, `UPDATE messages SET read_at = now() WHERE id = $1 AND read_at IS NULL`, id)
+	return err
+}
+
+// GetReceipt возвращает последнее сообщение отправителя получателю
+// вместе с временем его прочтения.
+func (s Store) GetReceipt(ctx context.Context, senderID, recepientID string) (*store.Message, error) {
+	row := s.conn.QueryRowContext(ctx, `
+        SELECT
+            m.id,
+            COALESCE(u.username, '') AS recepient,
+            m.payload,
+            m.sent_at,
+            m.read_at,
+            COALESCE(u.send_receipts, false)
+        FROM messages m
+        LEFT JOIN users u ON m.recepient = u.id
+        WHERE
+            m.sender = $1
+            AND m.recepient = $2
+            AND NOT m.reminder
+            AND (m.deliver_at IS NULL OR m.deliver_at <= now())
+        ORDER BY m.sent_at DESC, m.id DESC
+        LIMIT 1
+    `, senderID, recepientID)
+
+	var msg store.Message
+	var readAt sql.NullTime
+	var sendReceipts bool
+	err := row.Scan(&msg.ID, &msg.Recepient, &msg.Payload, &msg.Time, &readAt, &sendReceipts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !sendReceipts {
+		return nil, store.ErrReceiptsDisabled
+	}
+	msg.Sender = senderID
+	msg.ReadAt = readAt.Time
+	return &msg, nil
+}
+
+// TakeReadReceipts возвращает сообщения отправителя, прочитанные с момента
+// предыдущего вызова, и отмечает отчёты о них озвученными. Сообщения получателей,
+// запретивших отчёты о прочтении, не возвращаются.
+func (s Store) TakeReadReceipts(ctx context.Context, senderID string) ([]store.Message, error) {
+	rows, err := s.conn.QueryContext(ctx, `
+        UPDATE messages m
+        SET receipt_announced = true
+        FROM users u
+        WHERE
+            m.recepient = u.id
+            AND m.sender = $1
+            AND m.read_at IS NOT NULL
+            AND NOT m.receipt_announced
+            AND NOT m.reminder
+            AND u.send_receipts
+        RETURNING m.id, u.username, m.payload, m.read_at
+    `, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var messages []store.Message
+	for rows.Next() {
+		m := store.Message{Sender: senderID}
+		if err := rows.Scan(&m.ID, &m.Recepient, &m.Payload, &m.ReadAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (s Store) SetSendReceipts(ctx context.Context, userID string, enabled bool) error {
+	_, err := s.conn.ExecContext(ctx, `UPDATE users SET send_receipts = $2 WHERE id = $1`, userID, enabled)
+	return err
+}
+
+// SearchMessages ищет среди доступных получателю сообщений, самые новые идут первыми.
+func (s Store) SearchMessages(ctx context.Context, userID string, query store.SearchQuery) ([]store.Message, error) {
+	rows, err := s.conn.QueryContext(ctx, `
+        SELECT
+            m.id,
+            COALESCE(u.username, '') AS sender,
+            m.payload,
+            m.sent_at,
+            m.group_name
+        FROM messages m
+        LEFT JOIN users u ON m.sender = u.id
+        WHERE
+            m.recepient = $1
+            AND `+visibleMessage+`
+            AND ($2 = '' OR m.sender = $2)
+            AND ($3::timestamptz IS NULL OR m.sent_at >= $3)
+            AND ($4::timestamptz IS NULL OR m.sent_at < $4)
+            AND ($5 = '' OR to_tsvector('russian', m.payload) @@ plainto_tsquery('russian', $5))
+        ORDER BY m.sent_at DESC, m.id DESC
+        LIMIT $6
+    `,
+		userID,
+		query.SenderID,
+		sql.NullTime{Time: query.From, Valid: !query.From.IsZero()},
+		sql.NullTime{Time: query.To, Valid: !query.To.IsZero()},
+		query.Text,
+		query.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var messages []store.Message
+	for rows.Next() {
+		var m store.Message
+		var group sql.NullString
+		if err := rows.Scan(&m.ID, &m.Sender, &m.Payload, &m.Time, &group); err != nil {
+			return nil, err
+		}
+		m.Group = group.String
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// ReportMessage сохраняет жалобу получателя на сообщение вместе с его текстом,
+// чтобы она пережила удаление сообщения, и отмечает отправителя для проверки.
+func (s Store) ReportMessage(ctx context.Context, reporterID string, id int64) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	var senderID string
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO reports
+        (message_id, reporter, sender, payload)
+        SELECT id, recepient, sender, payload
+        FROM messages
+        WHERE id = $1 AND recepient = $2
+        RETURNING sender
+    `, id, reporterID).Scan(&senderID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			err = store.ErrConflict
+		}
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET flagged = true WHERE id = $1`, senderID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s Store) ListScheduled(ctx context.Context, senderID string) ([]store.Message, error) {
+	rows, err := s.conn.QueryContext(ctx, `
+        SELECT
+            m.id,
+            COALESCE(u.username, '') AS recepient,
+            m.payload,
+            m.deliver_at
+        FROM messages m
+        LEFT JOIN users u ON m.recepient = u.id
+        WHERE
+            m.sender = $1
+            AND m.deliver_at > now()
+            AND NOT m.reminder
+        ORDER BY m.deliver_at, m.id
+    `, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var messages []store.Message
+	for rows.Next() {
+		var m store.Message
+		if err := rows.Scan(&m.ID, &m.Recepient, &m.Payload, &m.DeliverAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+func (s Store) CancelScheduled(ctx context.Context, senderID string, id int64) error {
+	res, err := s.conn.ExecContext(ctx, `
+        DELETE FROM messages
+        WHERE
+            id = $2
+            AND sender = $1
+            AND deliver_at > now()
+            AND NOT reminder
+    `, senderID, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s Store) ListReminders(ctx context.Context, userID string) ([]store.Message, error) {
+	return s.queryReminders(ctx, `
+        SELECT id, payload, deliver_at, COALESCE(recurrence, '')
+        FROM messages
+        WHERE
+            recepient = $1
+            AND reminder
+            AND read_at IS NULL
+        ORDER BY deliver_at, id
+    `, userID)
+}
+
+func (s Store) ListDueReminders(ctx context.Context, userID string) ([]store.Message, error) {
+	return s.queryReminders(ctx, `
+        SELECT id, payload, deliver_at, COALESCE(recurrence, '')
+        FROM messages
+        WHERE
+            recepient = $1
+            AND reminder
+            AND read_at IS NULL
+            AND deliver_at <= now()
+        ORDER BY deliver_at, id
+    `, userID)
+}
+
+func (s Store) queryReminders(ctx context.Context, query string, userID string) ([]store.Message, error) {
+	rows, err := s.conn.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var reminders []store.Message
+	for rows.Next() {
+		m := store.Message{Sender: userID, Recepient: userID, Reminder: true}
+		if err := rows.Scan(&m.ID, &m.Payload, &m.DeliverAt, &m.Recurrence); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reminders, nil
+}
+
+// CompleteReminder отмечает напоминание озвученным. Если next не нулевое,
+// напоминание переносится на next и будет озвучено снова.
+func (s Store) CompleteReminder(ctx context.Context, id int64, next time.Time) error {
+	var err error
+	if next.IsZero() {
+		_, err = s.conn.ExecContext(ctx, `UPDATE messages SET read_at = now() WHERE id = $1 AND reminder`, id)
+	} else {
+		_, err = s.conn.ExecContext(ctx, `UPDATE messages SET deliver_at = $2 WHERE id = $1 AND reminder`, id, next)
+	}
+	return err
+}
+
+func (s Store) DeleteReminder(ctx context.Context, userID string, id int64) error {
+	res, err := s.conn.ExecContext(ctx, `DELETE FROM messages WHERE id = $2 AND recepient = $1 AND reminder`, userID, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// PurgeExpired удаляет не больше limit сообщений, у которых истёк собственный срок
+// жизни или срок хранения по policy, и возвращает число удалённых строк.
+// Если policy.Archive, сообщения переносятся в таблицу messages_archive.
+// Незавершённые напоминания сроком хранения непрочитанных не затрагиваются.
+func (s Store) PurgeExpired(ctx context.Context, policy store.RetentionPolicy, limit int) (int64, error) {
+	archive := ``
+	if policy.Archive {
+		archive = `, archived AS (INSERT INTO messages_archive (` + archiveColumns + `) SELECT ` + archiveColumns + ` FROM deleted)`
+	}
+
+	row := s.conn.QueryRowContext(ctx, `
+        WITH expired AS (
+            SELECT id
+            FROM messages
+            WHERE
+                expires_at <= now()
+                OR ($1::float8 > 0 AND read_at <= now() - make_interval(secs => $1::float8))
+                OR (
+                    $2::float8 > 0
+                    AND read_at IS NULL
+                    AND NOT reminder
+                    AND COALESCE(deliver_at, sent_at) <= now() - make_interval(secs => $2::float8)
+                )
+            LIMIT $3
+            FOR UPDATE SKIP LOCKED
+        ), deleted AS (
+            DELETE FROM messages m
+            USING expired e
+            WHERE m.id = e.id
+            RETURNING m.*
+        )`+archive+`
+        SELECT count(*) FROM deleted
+    `, policy.Read.Seconds(), policy.Unread.Seconds(), limit)
+
+	var removed int64
+	err := row.Scan(&removed)
+	return removed, err
+}
+
+func (s Store) CreateGroup(ctx context.Context, ownerID, name string) error {
+	_, err := s.conn.ExecContext(ctx, `
+        INSERT INTO groups
+        (owner, name)
+        VALUES
+        ($1, $2);
+    `, ownerID, name)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			err = store.ErrConflict
+		}
+	}
+
+	return err
+}
+
+func (s Store) AddGroupMember(ctx context.Context, ownerID, name, memberID string) error {
+	res, err := s.conn.ExecContext(ctx, `
+        INSERT INTO group_members
+        (group_id, member)
+        SELECT id, $3 FROM groups WHERE owner = $1 AND name = $2
+        ON CONFLICT DO NOTHING;
+    `, ownerID, name, memberID)
+	if err != nil {
+		return err
+	}
+
+	// ON CONFLICT не отличает повторное добавление от отсутствующей группы
+	return s.checkGroupExists(ctx, res, ownerID, name)
+}
+
+func (s Store) RemoveGroupMember(ctx context.Context, ownerID, name, memberID string) error {
+	res, err := s.conn.ExecContext(ctx, `
+        DELETE FROM group_members
+        WHERE
+            member = $3
+            AND group_id = (SELECT id FROM groups WHERE owner = $1 AND name = $2)
+    `, ownerID, name, memberID)
+	if err != nil {
+		return err
+	}
+
+	return s.checkGroupExists(ctx, res, ownerID, name)
+}
+
+func (s Store) ListGroupMembers(ctx context.Context, ownerID, name string) ([]string, error) {
+	rows, err := s.conn.QueryContext(ctx, `
+        SELECT gm.member
+        FROM group_members gm
+        JOIN groups g ON gm.group_id = g.id
+        WHERE
+            g.owner = $1
+            AND g.name = $2
+    `, ownerID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var members []string
+	for rows.Next() {
+		var member string
+		if err := rows.Scan(&member); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// ListGroups возвращает названия групп пользователя ownerID.
+func (s Store) ListGroups(ctx context.Context, ownerID string) ([]string, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT name FROM groups WHERE owner = $1 ORDER BY name`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// checkGroupExists возвращает store.ErrNotFound, если запрос не затронул
+// ни одной строки из-за того, что у пользователя нет такой группы.
+func (s Store) checkGroupExists(ctx context.Context, res sql.Result, ownerID, name string) error {
+	affected, err := res.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	var exists bool
+	row := s.conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM groups WHERE owner = $1 AND name = $2)`, ownerID, name)
+	if err := row.Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s Store) SaveContact(ctx context.Context, ownerID, alias, contactID string) error {
+	_, err := s.conn.ExecContext(ctx, `
+        INSERT INTO contacts
+        (owner, alias, contact)
+        VALUES
+        ($1, $2, $3);
+    `, ownerID, alias, contactID)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgerrcode.IsIntegrityConstraintViolation(pgErr.Code) {
+			err = store.ErrConflict
+		}
+	}
+
+	return err
+}
+
+func (s Store) RemoveContact(ctx context.Context, ownerID, alias string) error {
+	res, err := s.conn.ExecContext(ctx, `DELETE FROM contacts WHERE owner = $1 AND alias = $2`, ownerID, alias)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+func (s Store) FindContact(ctx context.Context, ownerID, alias string) (contactID string, err error) {
+	row := s.conn.QueryRowContext(ctx, `SELECT contact FROM contacts WHERE owner = $1 AND alias = $2`, ownerID, alias)
+	err = row.Scan(&contactID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = store.ErrNotFound
+	}
+	return
+}
+
+func (s Store) ListContacts(ctx context.Context, ownerID string) (map[string]string, error) {
+	rows, err := s.conn.QueryContext(ctx, `SELECT alias, contact FROM contacts WHERE owner = $1`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	contacts := make(map[string]string)
+	for rows.Next() {
+		var alias, contact string
+		if err := rows.Scan(&alias, &contact); err != nil {
+			return nil, err
+		}
+		contacts[alias] = contact
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return contacts, nil
+}
+
+func (s Store) BlockUser(ctx context.Context, ownerID, blockedID string) error {
+	_, err := s.conn.ExecContext(ctx, `
+        INSERT INTO blocks
+        (owner, blocked)
+        VALUES
+        ($1, $2)
+        ON CONFLICT DO NOTHING;
+    `, ownerID, blockedID)
+
+	return err
+}
+
+func (s Store) UnblockUser(ctx context.Context, ownerID, blockedID string) error {
+	_, err := s.conn.ExecContext(ctx, `DELETE FROM blocks WHERE owner = $1 AND blocked = $2`, ownerID, blockedID)
+	return err
+}
+
+func (s Store) SetContactsOnly(ctx context.Context, userID string, enabled bool) error {
+	_, err := s.conn.ExecContext(ctx, `UPDATE users SET contacts_only = $2 WHERE id = $1`, userID, enabled)
+	return err
+}
@@ -0,0 +1,33 @@
+package pg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Миграции выполняются при каждом запуске, поэтому повторный запуск
+// на уже обновлённой базе не должен падать.
+func TestMigrationsAreIdempotent(t *testing.T) {
+	for _, query := range migrations() {
+		query = strings.Join(strings.Fields(query), " ")
+		switch {
+		case strings.HasPrefix(query, "CREATE"), strings.HasPrefix(query, "ALTER"):
+			assert.Contains(t, query, "IF NOT EXISTS")
+		case strings.HasPrefix(query, "DROP"):
+			assert.Contains(t, query, "IF EXISTS")
+		case strings.HasPrefix(query, "DO"):
+			assert.Contains(t, query, "EXCEPTION WHEN duplicate_object")
+		default:
+			t.Errorf("unexpected migration %q", query)
+		}
+	}
+}
+
+func TestArchiveColumnsCoverMessageColumns(t *testing.T) {
+	for _, column := range messageColumns {
+		name := strings.Fields(column)[0]
+		assert.Contains(t, strings.Split(archiveColumns, ", "), name)
+	}
+}
@@ -0,0 +1,52 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TenantDSN возвращает строку подключения, в которой все запросы выполняются
+// в схеме schema. Так данные разных навыков хранятся в отдельных схемах
+// и запросы одного навыка не видят таблиц другого. Схему создаёт CreateSchema,
+// таблицы в ней — Bootstrap.
+func TenantDSN(dsn, schema string) (string, error) {
+	if !validSchema(schema) {
+		return "", fmt.Errorf("invalid tenant schema %q", schema)
+	}
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", err
+		}
+		query := u.Query()
+		query.Set("search_path", schema)
+		u.RawQuery = query.Encode()
+		return u.String(), nil
+	}
+
+	return strings.TrimSpace(dsn + " search_path=" + schema), nil
+}
+
+// CreateSchema создаёт схему schema, если её ещё нет.
+func CreateSchema(ctx context.Context, conn *sql.DB, schema string) error {
+	if !validSchema(schema) {
+		return fmt.Errorf("invalid tenant schema %q", schema)
+	}
+	_, err := conn.ExecContext(ctx, `CREATE SCHEMA IF NOT EXISTS `+pgx.Identifier{schema}.Sanitize())
+	return err
+}
+
+// validSchema допускает только буквы, цифры и подчёркивания,
+// чтобы имя схемы можно было безопасно подставить в строку подключения.
+func validSchema(schema string) bool {
+	return schema != "" && strings.IndexFunc(schema, func(r rune) bool {
+		return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) < 0
+}
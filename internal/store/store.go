@@ -6,20 +6,125 @@ import (
 	"time"
 )
 
-var ErrConflict = errors.New("data conflict")
+// Периодичность напоминаний.
+const (
+	RecurrenceDaily  = "daily"
+	RecurrenceWeekly = "weekly"
+)
+
+var (
+	ErrConflict = errors.New("data conflict")
+	ErrNotFound = errors.New("not found")
+	// ErrReceiptsDisabled возвращается, когда получатель запретил отчёты о прочтении.
+	ErrReceiptsDisabled = errors.New("read receipts disabled")
+)
 
 type Store interface {
+	Ping(ctx context.Context) error
 	FindRecepient(ctx context.Context, username string) (userID string, err error)
+	FindUsernames(ctx context.Context, initials []string) ([]string, error)
 	ListMessages(ctx context.Context, userID string) ([]Message, error)
 	GetMessage(ctx context.Context, id int64) (*Message, error)
 	SaveMessages(ctx context.Context, messages ...Message) error
+	SummarizeUnread(ctx context.Context, userID string) ([]SenderSummary, error)
+	ListUnread(ctx context.Context, userID string, limit int) ([]Message, error)
+	ListUnreadFrom(ctx context.Context, userID, senderID string) ([]Message, error)
+	MarkRead(ctx context.Context, id int64) error
+	SearchMessages(ctx context.Context, userID string, query SearchQuery) ([]Message, error)
+	GetReceipt(ctx context.Context, senderID, recepientID string) (*Message, error)
+	TakeReadReceipts(ctx context.Context, senderID string) ([]Message, error)
+	SetSendReceipts(ctx context.Context, userID string, enabled bool) error
+	ReportMessage(ctx context.Context, reporterID string, id int64) error
+	ListScheduled(ctx context.Context, senderID string) ([]Message, error)
+	CancelScheduled(ctx context.Context, senderID string, id int64) error
+	ListReminders(ctx context.Context, userID string) ([]Message, error)
+	ListDueReminders(ctx context.Context, userID string) ([]Message, error)
+	CompleteReminder(ctx context.Context, id int64, next time.Time) error
+	DeleteReminder(ctx context.Context, userID string, id int64) error
+	PurgeExpired(ctx context.Context, policy RetentionPolicy, limit int) (removed int64, err error)
 	RegisterUser(ctx context.Context, userID, username string) error
+	GetUser(ctx context.Context, userID string) (*User, error)
+	RenameUser(ctx context.Context, userID, username string) error
+	DeleteUser(ctx context.Context, userID string) error
+	MigrateUser(ctx context.Context, fromID, toID string) error
+	SaveToken(ctx context.Context, tokenHash, account string) error
+	FindTokenAccount(ctx context.Context, tokenHash string) (account string, err error)
+	CreateGroup(ctx context.Context, ownerID, name string) error
+	AddGroupMember(ctx context.Context, ownerID, name, memberID string) error
+	RemoveGroupMember(ctx context.Context, ownerID, name, memberID string) error
+	ListGroupMembers(ctx context.Context, ownerID, name string) (memberIDs []string, err error)
+	ListGroups(ctx context.Context, ownerID string) (names []string, err error)
+	SaveContact(ctx context.Context, ownerID, alias, contactID string) error
+	RemoveContact(ctx context.Context, ownerID, alias string) error
+	FindContact(ctx context.Context, ownerID, alias string) (contactID string, err error)
+	ListContacts(ctx context.Context, ownerID string) (contactIDs map[string]string, err error)
+	BlockUser(ctx context.Context, ownerID, blockedID string) error
+	UnblockUser(ctx context.Context, ownerID, blockedID string) error
+	SetContactsOnly(ctx context.Context, userID string, enabled bool) error
+}
+
+// RetentionPolicy задаёт, как долго хранятся сообщения.
+// Нулевой срок означает бессрочное хранение.
+type RetentionPolicy struct {
+	// Read отсчитывается от момента прочтения сообщения.
+	Read time.Duration
+	// Unread отсчитывается от момента доставки непрочитанного сообщения.
+	Unread time.Duration
+	// Archive включает перенос устаревших сообщений в архив вместо удаления.
+	Archive bool
+}
+
+// SearchQuery задаёт условия поиска по полученным сообщениям.
+// Пустые поля не ограничивают поиск.
+type SearchQuery struct {
+	// SenderID ограничивает поиск сообщениями одного отправителя.
+	SenderID string
+	// From и To ограничивают время отправки полуинтервалом [From, To).
+	From time.Time
+	To   time.Time
+	// Text ищется по словам текста сообщения с учётом русской морфологии.
+	Text  string
+	Limit int
+}
+
+// SenderSummary описывает непрочитанные сообщения от одного отправителя.
+type SenderSummary struct {
+	SenderID string
+	// Sender содержит имя отправителя или пустую строку, если он удалил аккаунт.
+	Sender string
+	Count  int
+	Latest time.Time
+}
+
+type User struct {
+	ID       string
+	Username string
 }
 
 type Message struct {
-	ID        int64
+	ID int64
+	// Sender содержит пустую строку, если отправитель удалил свой аккаунт.
 	Sender    string
 	Recepient string
 	Time      time.Time
 	Payload   string
+	// Group содержит имя группы, через которую доставлено сообщение,
+	// или пустую строку для личных сообщений.
+	Group string
+	// ReplyTo содержит идентификатор сообщения, на которое дан ответ,
+	// или 0, если сообщение не является ответом.
+	ReplyTo int64
+	// DeliverAt содержит момент, до которого сообщение скрыто от получателя,
+	// или нулевое время для немедленной доставки.
+	DeliverAt time.Time
+	// ReadAt содержит момент прочтения или нулевое время для непрочитанных.
+	ReadAt time.Time
+	// ExpiresAt содержит момент, после которого сообщение удаляется,
+	// или нулевое время для бессрочных сообщений.
+	ExpiresAt time.Time
+	// Reminder отмечает напоминание, которое пользователь создал сам себе.
+	// Напоминания не попадают в список сообщений и озвучиваются в начале сессии.
+	Reminder bool
+	// Recurrence содержит периодичность напоминания или пустую строку для разовых.
+	Recurrence string
 }
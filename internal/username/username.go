@@ -0,0 +1,123 @@
+// Package username проверяет имена пользователей при регистрации и смене имени.
+package username
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/VladimirAzanza/alisa_skill/internal/moderation"
+	"github.com/VladimirAzanza/alisa_skill/internal/plural"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength ограничивает длину имени размером колонки users.username.
+const MaxLength = 128
+
+// Error описывает причину, по которой имя отклонено.
+// Текст ошибки предназначен для озвучивания пользователю.
+type Error struct {
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return e.Reason
+}
+
+// Policy задаёт правила, которым должно соответствовать имя пользователя.
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// Punctuation перечисляет допустимые символы помимо букв и цифр.
+	Punctuation string
+	// Reserved содержит имена, которые нельзя занять целиком.
+	Reserved []string
+	// Forbidden содержит корни слов, с которых не может начинаться
+	// слово в имени, в том числе после приставки.
+	Forbidden []string
+}
+
+// DefaultPolicy возвращает правила, используемые по умолчанию.
+func DefaultPolicy() Policy {
+	return Policy{
+		MinLength:   2,
+		MaxLength:   32,
+		Punctuation: "-",
+		Reserved: []string{
+			"все", "всем", "всех", "никто", "никому", "я", "меня", "мне",
+			"алиса", "админ", "администратор", "поддержка", "группа", "контакт",
+		},
+		Forbidden: []string{"хуй", "пизд", "ебан", "ебат", "бляд", "мудак"},
+	}
+}
+
+// Canonical возвращает форму имени, по которой проверяется уникальность:
+// NFC, нижний регистр и «е» вместо «ё».
+func Canonical(name string) string {
+	name = strings.ToLower(norm.NFC.String(name))
+	return strings.ReplaceAll(name, "ё", "е")
+}
+
+// Normalize приводит имя к виду, в котором оно сохраняется: NFC без пробелов по краям.
+func Normalize(name string) string {
+	return strings.TrimSpace(norm.NFC.String(name))
+}
+
+// Validate проверяет нормализованное имя и возвращает *Error
+// с объяснением, если имя не подходит.
+func (p Policy) Validate(name string) error {
+	length := utf8.RuneCountInString(name)
+	if length == 0 {
+		return &Error{Reason: "Вы не назвали имя. Скажите, например: «Зарегистрируй Иван»."}
+	}
+	if length < p.MinLength {
+		return &Error{Reason: fmt.Sprintf("Имя слишком короткое, нужно хотя бы %d %s.", p.MinLength, plural.Form(p.MinLength, "символ", "символа", "символов"))}
+	}
+	if maxLength := min(p.MaxLength, MaxLength); length > maxLength {
+		return &Error{Reason: fmt.Sprintf("Имя слишком длинное, можно не больше %d %s.", maxLength, plural.Form(maxLength, "символ", "символа", "символов"))}
+	}
+
+	for i, r := range name {
+		if unicode.IsLetter(r) {
+			continue
+		}
+		if i == 0 {
+			return &Error{Reason: "Имя должно начинаться с буквы."}
+		}
+		if !unicode.IsDigit(r) && !strings.ContainsRune(p.Punctuation, r) {
+			return &Error{Reason: "В имени можно использовать только буквы, цифры и дефис."}
+		}
+	}
+
+	canonical := Canonical(name)
+	for _, reserved := range p.Reserved {
+		if canonical == Canonical(reserved) {
+			return &Error{Reason: fmt.Sprintf("Имя %s зарезервировано, выберите другое.", name)}
+		}
+	}
+	// корни ищутся в начале слов, как в фильтре сообщений: иначе под запрет
+	// попадают обычные фамилии вроде «Гребанов»
+	forbidden := moderation.Dictionary{Words: p.Forbidden, Reject: true}
+	if _, err := forbidden.Moderate(context.Background(), canonical); errors.Is(err, moderation.ErrRejected) {
+		return &Error{Reason: "Такое имя использовать нельзя, выберите другое."}
+	}
+
+	return nil
+}
+
+// Alternatives возвращает варианты имени, которые можно предложить,
+// если само имя уже занято. Варианты соответствуют правилам p.
+func (p Policy) Alternatives(name string, count int) []string {
+	var alternatives []string
+	for i := 2; len(alternatives) < count && i < 100; i++ {
+		candidate := name + strconv.Itoa(i)
+		if p.Validate(candidate) == nil {
+			alternatives = append(alternatives, candidate)
+		}
+	}
+	return alternatives
+}
@@ -0,0 +1,55 @@
+package username
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	policy := DefaultPolicy()
+
+	testCases := []struct {
+		name  string
+		valid bool
+	}{
+		{name: "Иван", valid: true},
+		{name: "Anna-Maria2", valid: true},
+		{name: "", valid: false},
+		{name: "Я", valid: false},
+		{name: strings.Repeat("а", 33), valid: false},
+		{name: "2pac", valid: false},
+		{name: "Иван!", valid: false},
+		{name: "Всем", valid: false},
+		{name: "Алиса", valid: false},
+		{name: "Мудак", valid: false},
+		{name: "вася-мудак", valid: false},
+		{name: "Гребанов", valid: true},
+		{name: "Небатов", valid: true},
+		{name: "Колебанов", valid: true},
+		{name: "Оскребанов", valid: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Validate(tc.name)
+			if tc.valid {
+				assert.NoError(t, err)
+				return
+			}
+			var reason *Error
+			assert.ErrorAs(t, err, &reason)
+		})
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	assert.Equal(t, Canonical("Алёна"), Canonical("алена"))
+	// «й», записанная как «и» с комбинируемым знаком, совпадает с составным символом
+	assert.Equal(t, Canonical("Андрей"), Canonical("Андре\u0438\u0306"))
+}
+
+func TestAlternatives(t *testing.T) {
+	assert.Equal(t, []string{"Иван2", "Иван3"}, DefaultPolicy().Alternatives("Иван", 2))
+}
@@ -12,6 +12,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	store "github.com/VladimirAzanza/alisa_skill/internal/store"
 	gomock "go.uber.org/mock/gomock"
@@ -41,6 +42,119 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// AddGroupMember mocks base method.
+func (m *MockStore) AddGroupMember(ctx context.Context, ownerID, name, memberID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddGroupMember", ctx, ownerID, name, memberID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddGroupMember indicates an expected call of AddGroupMember.
+func (mr *MockStoreMockRecorder) AddGroupMember(ctx, ownerID, name, memberID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddGroupMember", reflect.TypeOf((*MockStore)(nil).AddGroupMember), ctx, ownerID, name, memberID)
+}
+
+// BlockUser mocks base method.
+func (m *MockStore) BlockUser(ctx context.Context, ownerID, blockedID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockUser", ctx, ownerID, blockedID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BlockUser indicates an expected call of BlockUser.
+func (mr *MockStoreMockRecorder) BlockUser(ctx, ownerID, blockedID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockUser", reflect.TypeOf((*MockStore)(nil).BlockUser), ctx, ownerID, blockedID)
+}
+
+// CancelScheduled mocks base method.
+func (m *MockStore) CancelScheduled(ctx context.Context, senderID string, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelScheduled", ctx, senderID, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelScheduled indicates an expected call of CancelScheduled.
+func (mr *MockStoreMockRecorder) CancelScheduled(ctx, senderID, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelScheduled", reflect.TypeOf((*MockStore)(nil).CancelScheduled), ctx, senderID, id)
+}
+
+// CompleteReminder mocks base method.
+func (m *MockStore) CompleteReminder(ctx context.Context, id int64, next time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteReminder", ctx, id, next)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteReminder indicates an expected call of CompleteReminder.
+func (mr *MockStoreMockRecorder) CompleteReminder(ctx, id, next any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteReminder", reflect.TypeOf((*MockStore)(nil).CompleteReminder), ctx, id, next)
+}
+
+// CreateGroup mocks base method.
+func (m *MockStore) CreateGroup(ctx context.Context, ownerID, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateGroup", ctx, ownerID, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateGroup indicates an expected call of CreateGroup.
+func (mr *MockStoreMockRecorder) CreateGroup(ctx, ownerID, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateGroup", reflect.TypeOf((*MockStore)(nil).CreateGroup), ctx, ownerID, name)
+}
+
+// DeleteReminder mocks base method.
+func (m *MockStore) DeleteReminder(ctx context.Context, userID string, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteReminder", ctx, userID, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteReminder indicates an expected call of DeleteReminder.
+func (mr *MockStoreMockRecorder) DeleteReminder(ctx, userID, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteReminder", reflect.TypeOf((*MockStore)(nil).DeleteReminder), ctx, userID, id)
+}
+
+// DeleteUser mocks base method.
+func (m *MockStore) DeleteUser(ctx context.Context, userID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUser", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUser indicates an expected call of DeleteUser.
+func (mr *MockStoreMockRecorder) DeleteUser(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockStore)(nil).DeleteUser), ctx, userID)
+}
+
+// FindContact mocks base method.
+func (m *MockStore) FindContact(ctx context.Context, ownerID, alias string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindContact", ctx, ownerID, alias)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindContact indicates an expected call of FindContact.
+func (mr *MockStoreMockRecorder) FindContact(ctx, ownerID, alias any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindContact", reflect.TypeOf((*MockStore)(nil).FindContact), ctx, ownerID, alias)
+}
+
 // FindRecepient mocks base method.
 func (m *MockStore) FindRecepient(ctx context.Context, username string) (string, error) {
 	m.ctrl.T.Helper()
@@ -56,6 +170,36 @@ func (mr *MockStoreMockRecorder) FindRecepient(ctx, username any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindRecepient", reflect.TypeOf((*MockStore)(nil).FindRecepient), ctx, username)
 }
 
+// FindTokenAccount mocks base method.
+func (m *MockStore) FindTokenAccount(ctx context.Context, tokenHash string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindTokenAccount", ctx, tokenHash)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindTokenAccount indicates an expected call of FindTokenAccount.
+func (mr *MockStoreMockRecorder) FindTokenAccount(ctx, tokenHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindTokenAccount", reflect.TypeOf((*MockStore)(nil).FindTokenAccount), ctx, tokenHash)
+}
+
+// FindUsernames mocks base method.
+func (m *MockStore) FindUsernames(ctx context.Context, initials []string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindUsernames", ctx, initials)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindUsernames indicates an expected call of FindUsernames.
+func (mr *MockStoreMockRecorder) FindUsernames(ctx, initials any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUsernames", reflect.TypeOf((*MockStore)(nil).FindUsernames), ctx, initials)
+}
+
 // GetMessage mocks base method.
 func (m *MockStore) GetMessage(ctx context.Context, id int64) (*store.Message, error) {
 	m.ctrl.T.Helper()
@@ -71,6 +215,96 @@ func (mr *MockStoreMockRecorder) GetMessage(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMessage", reflect.TypeOf((*MockStore)(nil).GetMessage), ctx, id)
 }
 
+// GetReceipt mocks base method.
+func (m *MockStore) GetReceipt(ctx context.Context, senderID, recepientID string) (*store.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReceipt", ctx, senderID, recepientID)
+	ret0, _ := ret[0].(*store.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReceipt indicates an expected call of GetReceipt.
+func (mr *MockStoreMockRecorder) GetReceipt(ctx, senderID, recepientID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReceipt", reflect.TypeOf((*MockStore)(nil).GetReceipt), ctx, senderID, recepientID)
+}
+
+// GetUser mocks base method.
+func (m *MockStore) GetUser(ctx context.Context, userID string) (*store.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, userID)
+	ret0, _ := ret[0].(*store.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockStoreMockRecorder) GetUser(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockStore)(nil).GetUser), ctx, userID)
+}
+
+// ListContacts mocks base method.
+func (m *MockStore) ListContacts(ctx context.Context, ownerID string) (map[string]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListContacts", ctx, ownerID)
+	ret0, _ := ret[0].(map[string]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListContacts indicates an expected call of ListContacts.
+func (mr *MockStoreMockRecorder) ListContacts(ctx, ownerID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListContacts", reflect.TypeOf((*MockStore)(nil).ListContacts), ctx, ownerID)
+}
+
+// ListDueReminders mocks base method.
+func (m *MockStore) ListDueReminders(ctx context.Context, userID string) ([]store.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDueReminders", ctx, userID)
+	ret0, _ := ret[0].([]store.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDueReminders indicates an expected call of ListDueReminders.
+func (mr *MockStoreMockRecorder) ListDueReminders(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDueReminders", reflect.TypeOf((*MockStore)(nil).ListDueReminders), ctx, userID)
+}
+
+// ListGroupMembers mocks base method.
+func (m *MockStore) ListGroupMembers(ctx context.Context, ownerID, name string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListGroupMembers", ctx, ownerID, name)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListGroupMembers indicates an expected call of ListGroupMembers.
+func (mr *MockStoreMockRecorder) ListGroupMembers(ctx, ownerID, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListGroupMembers", reflect.TypeOf((*MockStore)(nil).ListGroupMembers), ctx, ownerID, name)
+}
+
+// ListGroups mocks base method.
+func (m *MockStore) ListGroups(ctx context.Context, ownerID string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListGroups", ctx, ownerID)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListGroups indicates an expected call of ListGroups.
+func (mr *MockStoreMockRecorder) ListGroups(ctx, ownerID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListGroups", reflect.TypeOf((*MockStore)(nil).ListGroups), ctx, ownerID)
+}
+
 // ListMessages mocks base method.
 func (m *MockStore) ListMessages(ctx context.Context, userID string) ([]store.Message, error) {
 	m.ctrl.T.Helper()
@@ -86,16 +320,323 @@ func (mr *MockStoreMockRecorder) ListMessages(ctx, userID any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListMessages", reflect.TypeOf((*MockStore)(nil).ListMessages), ctx, userID)
 }
 
-// SaveMessage mocks base method.
-func (m *MockStore) SaveMessage(ctx context.Context, userID string, msg store.Message) error {
+// ListReminders mocks base method.
+func (m *MockStore) ListReminders(ctx context.Context, userID string) ([]store.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListReminders", ctx, userID)
+	ret0, _ := ret[0].([]store.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListReminders indicates an expected call of ListReminders.
+func (mr *MockStoreMockRecorder) ListReminders(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListReminders", reflect.TypeOf((*MockStore)(nil).ListReminders), ctx, userID)
+}
+
+// ListScheduled mocks base method.
+func (m *MockStore) ListScheduled(ctx context.Context, senderID string) ([]store.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScheduled", ctx, senderID)
+	ret0, _ := ret[0].([]store.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScheduled indicates an expected call of ListScheduled.
+func (mr *MockStoreMockRecorder) ListScheduled(ctx, senderID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScheduled", reflect.TypeOf((*MockStore)(nil).ListScheduled), ctx, senderID)
+}
+
+// ListUnread mocks base method.
+func (m *MockStore) ListUnread(ctx context.Context, userID string, limit int) ([]store.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUnread", ctx, userID, limit)
+	ret0, _ := ret[0].([]store.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUnread indicates an expected call of ListUnread.
+func (mr *MockStoreMockRecorder) ListUnread(ctx, userID, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUnread", reflect.TypeOf((*MockStore)(nil).ListUnread), ctx, userID, limit)
+}
+
+// ListUnreadFrom mocks base method.
+func (m *MockStore) ListUnreadFrom(ctx context.Context, userID, senderID string) ([]store.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUnreadFrom", ctx, userID, senderID)
+	ret0, _ := ret[0].([]store.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUnreadFrom indicates an expected call of ListUnreadFrom.
+func (mr *MockStoreMockRecorder) ListUnreadFrom(ctx, userID, senderID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUnreadFrom", reflect.TypeOf((*MockStore)(nil).ListUnreadFrom), ctx, userID, senderID)
+}
+
+// MarkRead mocks base method.
+func (m *MockStore) MarkRead(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkRead", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkRead indicates an expected call of MarkRead.
+func (mr *MockStoreMockRecorder) MarkRead(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkRead", reflect.TypeOf((*MockStore)(nil).MarkRead), ctx, id)
+}
+
+// MigrateUser mocks base method.
+func (m *MockStore) MigrateUser(ctx context.Context, fromID, toID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateUser", ctx, fromID, toID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MigrateUser indicates an expected call of MigrateUser.
+func (mr *MockStoreMockRecorder) MigrateUser(ctx, fromID, toID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateUser", reflect.TypeOf((*MockStore)(nil).MigrateUser), ctx, fromID, toID)
+}
+
+// Ping mocks base method.
+func (m *MockStore) Ping(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockStoreMockRecorder) Ping(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStore)(nil).Ping), ctx)
+}
+
+// PurgeExpired mocks base method.
+func (m *MockStore) PurgeExpired(ctx context.Context, policy store.RetentionPolicy, limit int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeExpired", ctx, policy, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeExpired indicates an expected call of PurgeExpired.
+func (mr *MockStoreMockRecorder) PurgeExpired(ctx, policy, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeExpired", reflect.TypeOf((*MockStore)(nil).PurgeExpired), ctx, policy, limit)
+}
+
+// RegisterUser mocks base method.
+func (m *MockStore) RegisterUser(ctx context.Context, userID, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegisterUser", ctx, userID, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RegisterUser indicates an expected call of RegisterUser.
+func (mr *MockStoreMockRecorder) RegisterUser(ctx, userID, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterUser", reflect.TypeOf((*MockStore)(nil).RegisterUser), ctx, userID, username)
+}
+
+// RemoveContact mocks base method.
+func (m *MockStore) RemoveContact(ctx context.Context, ownerID, alias string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveContact", ctx, ownerID, alias)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveContact indicates an expected call of RemoveContact.
+func (mr *MockStoreMockRecorder) RemoveContact(ctx, ownerID, alias any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveContact", reflect.TypeOf((*MockStore)(nil).RemoveContact), ctx, ownerID, alias)
+}
+
+// RemoveGroupMember mocks base method.
+func (m *MockStore) RemoveGroupMember(ctx context.Context, ownerID, name, memberID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveGroupMember", ctx, ownerID, name, memberID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveGroupMember indicates an expected call of RemoveGroupMember.
+func (mr *MockStoreMockRecorder) RemoveGroupMember(ctx, ownerID, name, memberID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveGroupMember", reflect.TypeOf((*MockStore)(nil).RemoveGroupMember), ctx, ownerID, name, memberID)
+}
+
+// RenameUser mocks base method.
+func (m *MockStore) RenameUser(ctx context.Context, userID, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenameUser", ctx, userID, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RenameUser indicates an expected call of RenameUser.
+func (mr *MockStoreMockRecorder) RenameUser(ctx, userID, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenameUser", reflect.TypeOf((*MockStore)(nil).RenameUser), ctx, userID, username)
+}
+
+// ReportMessage mocks base method.
+func (m *MockStore) ReportMessage(ctx context.Context, reporterID string, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReportMessage", ctx, reporterID, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReportMessage indicates an expected call of ReportMessage.
+func (mr *MockStoreMockRecorder) ReportMessage(ctx, reporterID, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReportMessage", reflect.TypeOf((*MockStore)(nil).ReportMessage), ctx, reporterID, id)
+}
+
+// SaveContact mocks base method.
+func (m *MockStore) SaveContact(ctx context.Context, ownerID, alias, contactID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveContact", ctx, ownerID, alias, contactID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveContact indicates an expected call of SaveContact.
+func (mr *MockStoreMockRecorder) SaveContact(ctx, ownerID, alias, contactID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveContact", reflect.TypeOf((*MockStore)(nil).SaveContact), ctx, ownerID, alias, contactID)
+}
+
+// SaveMessages mocks base method.
+func (m *MockStore) SaveMessages(ctx context.Context, messages ...store.Message) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range messages {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "SaveMessages", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveMessages indicates an expected call of SaveMessages.
+func (mr *MockStoreMockRecorder) SaveMessages(ctx any, messages ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, messages...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMessages", reflect.TypeOf((*MockStore)(nil).SaveMessages), varargs...)
+}
+
+// SaveToken mocks base method.
+func (m *MockStore) SaveToken(ctx context.Context, tokenHash, account string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveToken", ctx, tokenHash, account)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveToken indicates an expected call of SaveToken.
+func (mr *MockStoreMockRecorder) SaveToken(ctx, tokenHash, account any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveToken", reflect.TypeOf((*MockStore)(nil).SaveToken), ctx, tokenHash, account)
+}
+
+// SearchMessages mocks base method.
+func (m *MockStore) SearchMessages(ctx context.Context, userID string, query store.SearchQuery) ([]store.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchMessages", ctx, userID, query)
+	ret0, _ := ret[0].([]store.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchMessages indicates an expected call of SearchMessages.
+func (mr *MockStoreMockRecorder) SearchMessages(ctx, userID, query any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchMessages", reflect.TypeOf((*MockStore)(nil).SearchMessages), ctx, userID, query)
+}
+
+// SetContactsOnly mocks base method.
+func (m *MockStore) SetContactsOnly(ctx context.Context, userID string, enabled bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetContactsOnly", ctx, userID, enabled)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetContactsOnly indicates an expected call of SetContactsOnly.
+func (mr *MockStoreMockRecorder) SetContactsOnly(ctx, userID, enabled any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetContactsOnly", reflect.TypeOf((*MockStore)(nil).SetContactsOnly), ctx, userID, enabled)
+}
+
+// SetSendReceipts mocks base method.
+func (m *MockStore) SetSendReceipts(ctx context.Context, userID string, enabled bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSendReceipts", ctx, userID, enabled)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSendReceipts indicates an expected call of SetSendReceipts.
+func (mr *MockStoreMockRecorder) SetSendReceipts(ctx, userID, enabled any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSendReceipts", reflect.TypeOf((*MockStore)(nil).SetSendReceipts), ctx, userID, enabled)
+}
+
+// SummarizeUnread mocks base method.
+func (m *MockStore) SummarizeUnread(ctx context.Context, userID string) ([]store.SenderSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SummarizeUnread", ctx, userID)
+	ret0, _ := ret[0].([]store.SenderSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SummarizeUnread indicates an expected call of SummarizeUnread.
+func (mr *MockStoreMockRecorder) SummarizeUnread(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SummarizeUnread", reflect.TypeOf((*MockStore)(nil).SummarizeUnread), ctx, userID)
+}
+
+// TakeReadReceipts mocks base method.
+func (m *MockStore) TakeReadReceipts(ctx context.Context, senderID string) ([]store.Message, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeReadReceipts", ctx, senderID)
+	ret0, _ := ret[0].([]store.Message)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeReadReceipts indicates an expected call of TakeReadReceipts.
+func (mr *MockStoreMockRecorder) TakeReadReceipts(ctx, senderID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeReadReceipts", reflect.TypeOf((*MockStore)(nil).TakeReadReceipts), ctx, senderID)
+}
+
+// UnblockUser mocks base method.
+func (m *MockStore) UnblockUser(ctx context.Context, ownerID, blockedID string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SaveMessage", ctx, userID, msg)
+	ret := m.ctrl.Call(m, "UnblockUser", ctx, ownerID, blockedID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// SaveMessage indicates an expected call of SaveMessage.
-func (mr *MockStoreMockRecorder) SaveMessage(ctx, userID, msg any) *gomock.Call {
+// UnblockUser indicates an expected call of UnblockUser.
+func (mr *MockStoreMockRecorder) UnblockUser(ctx, ownerID, blockedID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMessage", reflect.TypeOf((*MockStore)(nil).SaveMessage), ctx, userID, msg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnblockUser", reflect.TypeOf((*MockStore)(nil).UnblockUser), ctx, ownerID, blockedID)
 }